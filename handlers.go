@@ -41,9 +41,17 @@ func (cfg *apiConfig) handlerMetrics(w http.ResponseWriter, r *http.Request) {
 func (cfg *apiConfig) handlerReset(w http.ResponseWriter, r *http.Request) {
 	if cfg.platform != "dev" {
 		log.Printf("Not running on development machine")
-		w.WriteHeader(http.StatusForbidden)
+		respondWithError(w, http.StatusForbidden, "reset_not_allowed", "Reset is only allowed on the dev platform")
 		return
 	}
+	//media rows go with their users, so collect the files to remove first
+	blobKeys, err := cfg.queries.DeleteAllMedia(r.Context())
+	if err != nil {
+		log.Printf("Couldn't delete media: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	cfg.deleteBlobs(r.Context(), blobKeys)
 	cfg.queries.DeleteUsers(r.Context())
 	cfg.fileServerHits.Store(0)
 	w.WriteHeader(http.StatusOK)
@@ -1,97 +1,205 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
-	"sort"
-	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
-	"github.com/skarsden/Chirp/internal/auth"
 	"github.com/skarsden/Chirp/internal/database"
+	"github.com/skarsden/Chirp/internal/moderation"
+	"github.com/skarsden/Chirp/internal/webhooks"
+)
+
+const (
+	maxChirpLength = 140
+	maxChirpMedia  = 4
 )
 
 type Chirp struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"`
+	ID           uuid.UUID     `json:"id"`
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+	Body         string        `json:"body"`
+	UserID       uuid.UUID     `json:"user_id"`
+	InReplyTo    *uuid.UUID    `json:"in_reply_to"`
+	IsDeleted    bool          `json:"is_deleted,omitempty"`
+	LikeCount    int32         `json:"like_count"`
+	RechirpCount int32         `json:"rechirp_count"`
+	LikedByMe    *bool         `json:"liked_by_me,omitempty"`
+	Entities     []ChirpEntity `json:"entities"`
+	Media        []Media       `json:"media"`
+}
+
+// Convert database chirp to response chirp
+func chirpFromDB(dbChirp database.Chirp) Chirp {
+	chirp := Chirp{
+		ID:           dbChirp.ID,
+		CreatedAt:    dbChirp.CreatedAt,
+		UpdatedAt:    dbChirp.UpdatedAt,
+		Body:         dbChirp.Body,
+		UserID:       dbChirp.UserID,
+		IsDeleted:    dbChirp.DeletedAt.Valid,
+		LikeCount:    dbChirp.LikeCount,
+		RechirpCount: dbChirp.RechirpCount,
+		Entities:     hashtagEntities(dbChirp.Body),
+		Media:        []Media{},
+	}
+	if dbChirp.InReplyTo.Valid {
+		chirp.InReplyTo = &dbChirp.InReplyTo.UUID
+	}
+	return chirp
 }
 
 // Post Chirp
 func (cfg *apiConfig) handlerPostChirp(w http.ResponseWriter, r *http.Request) {
 	type chirpParams struct {
-		Body string `json:"body"`
+		Body      string      `json:"body"`
+		InReplyTo *uuid.UUID  `json:"in_reply_to"`
+		MediaIDs  []uuid.UUID `json:"media_ids"`
 	}
 
-	//Get access token and validate it with user
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		log.Printf("Couldn't find access token: %s\n", err)
-		w.WriteHeader(http.StatusUnauthorized)
-		return
-	}
-
-	userID, err := auth.ValidateJWT(token, cfg.secret)
-	if err != nil {
-		log.Printf("Couldn't validate access token: %s\n", err)
-		w.WriteHeader(http.StatusUnauthorized)
-		return
-	}
+	userID := requestUserID(r)
 
 	//decode request JSON into struct
 	decoder := json.NewDecoder(r.Body)
 	chirp := chirpParams{}
-	err = decoder.Decode(&chirp)
+	err := decoder.Decode(&chirp)
 	if err != nil {
 		log.Printf("Error decoding json: %s\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondWithError(w, http.StatusBadRequest, "invalid_json", "Request body is not valid JSON")
 		return
 	}
 
 	//verify that text does not exceed 140 characters
-	if len(chirp.Body) > 140 {
+	if utf8.RuneCountInString(chirp.Body) > maxChirpLength {
 		log.Printf("Chirp exceeds 140 characters")
-		w.WriteHeader(http.StatusBadRequest)
+		respondChirpTooLong(w)
 		return
 	}
 
-	//look for "profanity" and clean body
-	stringSlice := strings.Split(chirp.Body, " ")
-	for i, s := range stringSlice {
-		if strings.ToLower(s) == "kerfuffle" || strings.ToLower(s) == "sharbert" || strings.ToLower(s) == "fornax" {
-			stringSlice[i] = "****"
+	//attached media must have been uploaded by the author and not used yet
+	mediaIDs := uniqueUUIDs(chirp.MediaIDs)
+	if len(mediaIDs) > maxChirpMedia {
+		log.Printf("Chirp has more than %d media attachments\n", maxChirpMedia)
+		detail := fmt.Sprintf("A chirp can have at most %d media attachments", maxChirpMedia)
+		respondWithProblem(w, newProblem(http.StatusBadRequest, "too_many_media", detail).
+			with("limit", maxChirpMedia).
+			withFieldErrors(FieldError{Field: "media_ids", Code: "too_many", Message: detail, Limit: maxChirpMedia}))
+		return
+	}
+
+	//replies can only be made to chirps that still exist
+	inReplyTo := uuid.NullUUID{}
+	if chirp.InReplyTo != nil {
+		parent, err := cfg.queries.GetChirp(r.Context(), *chirp.InReplyTo)
+		if err != nil || parent.DeletedAt.Valid {
+			log.Printf("Couldn't find chirp being replied to: %s\n", chirp.InReplyTo)
+			respondWithFieldError(w, http.StatusBadRequest, "invalid_reply_target", "in_reply_to", "The chirp being replied to doesn't exist")
+			return
 		}
+		inReplyTo = uuid.NullUUID{UUID: parent.ID, Valid: true}
+	}
+
+	//run body through moderation filters
+	moderated := cfg.moderator.Moderate(chirp.Body)
+	if moderated.Rejected {
+		log.Printf("Chirp rejected by moderation: %s\n", moderated.Reasons[0])
+		respondModerationRejected(w, moderated.Reasons[0])
+		return
+	}
+
+	//post body to database, together with any moderation flags
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %s\n", err)
+		respondWithInternalError(w)
+		return
 	}
+	defer tx.Rollback()
+	qtx := cfg.queries.WithTx(tx)
 
-	//post body to database
-	chirpResp, err := cfg.queries.CreateChirp(r.Context(), database.CreateChirpParams{
-		Body:   strings.Join(stringSlice, " "),
-		UserID: userID,
+	chirpResp, err := qtx.CreateChirp(r.Context(), database.CreateChirpParams{
+		Body:      moderated.Body,
+		UserID:    userID,
+		InReplyTo: inReplyTo,
 	})
 	if err != nil {
 		log.Printf("Error posting creating chirp: %s\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondWithInternalError(w)
 		return
 	}
 
-	//text is valid, set response to valid and marshal it, and write it back
-	respBody := Chirp{
-		ID:        chirpResp.ID,
-		CreatedAt: chirpResp.CreatedAt,
-		UpdatedAt: chirpResp.UpdatedAt,
-		Body:      chirpResp.Body,
-		UserID:    userID,
+	err = flagChirp(r.Context(), qtx, chirpResp.ID, moderated)
+	if err != nil {
+		log.Printf("Error flagging chirp: %s\n", err)
+		respondWithInternalError(w)
+		return
 	}
 
+	err = saveChirpEntities(r.Context(), qtx, chirpResp.ID, chirpResp.Body)
+	if err != nil {
+		log.Printf("Error saving chirp hashtags and mentions: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	if len(mediaIDs) > 0 {
+		attached, err := qtx.AttachMediaToChirp(r.Context(), database.AttachMediaToChirpParams{
+			ChirpID:  uuid.NullUUID{UUID: chirpResp.ID, Valid: true},
+			MediaIds: mediaIDs,
+			UserID:   userID,
+		})
+		if err != nil {
+			log.Printf("Error attaching media: %s\n", err)
+			respondWithInternalError(w)
+			return
+		}
+		if attached != int64(len(mediaIDs)) {
+			log.Printf("Media not found or already attached\n")
+			respondWithFieldError(w, http.StatusBadRequest, "invalid_media", "media_ids", "Media must be uploaded by you and not attached to another chirp")
+			return
+		}
+	}
+
+	//the event carries the same media and mentions as the response, so they're read before committing
+	respBody := chirpFromDB(chirpResp)
+	err = setChirpDetails(r.Context(), qtx, []*Chirp{&respBody})
+	if err != nil {
+		log.Printf("Couldn't enrich chirps: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	err = enqueueWebhookEvent(r.Context(), qtx, webhooks.EventChirpCreated, respBody)
+	if err != nil {
+		log.Printf("Couldn't queue chirp webhooks: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("Error committing chirp: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	//nobody has liked a chirp that was just posted
+	likedByMe := false
+	respBody.LikedByMe = &likedByMe
+
 	data, err := json.Marshal(respBody)
 
 	if err != nil {
 		log.Printf("Error marshalling JSON: %s", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondWithInternalError(w)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -99,53 +207,137 @@ func (cfg *apiConfig) handlerPostChirp(w http.ResponseWriter, r *http.Request) {
 	w.Write(data)
 }
 
-// Get Chirps
-func (cfg *apiConfig) handlerGetChirps(w http.ResponseWriter, r *http.Request) {
-	dbChirps, err := cfg.queries.GetChirps(r.Context())
+// Fill in fields that need extra lookups: mention entities, media and, for signed in viewers, liked_by_me
+func (cfg *apiConfig) enrichChirps(ctx context.Context, viewerID uuid.NullUUID, chirps []*Chirp) error {
+	err := setChirpDetails(ctx, cfg.queries, chirps)
 	if err != nil {
-		log.Printf("Error getting chirps: %s\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		return err
+	}
+	if !viewerID.Valid {
+		return nil
+	}
+	return cfg.setLikedByMe(ctx, viewerID.UUID, chirps)
+}
+
+// Fill in the fields that are the same for every viewer, mention entities and media.
+// Reading through q lets a chirp be filled in inside the transaction creating it
+func setChirpDetails(ctx context.Context, q *database.Queries, chirps []*Chirp) error {
+	err := setMentionEntities(ctx, q, chirps)
+	if err != nil {
+		return err
+	}
+	return setChirpMedia(ctx, q, chirps)
+}
+
+// Drop repeated ids, keeping the first occurrence
+func uniqueUUIDs(ids []uuid.UUID) []uuid.UUID {
+	seen := map[uuid.UUID]bool{}
+	unique := []uuid.UUID{}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// Store moderation flags so the chirp shows up for review
+func flagChirp(ctx context.Context, queries *database.Queries, chirpID uuid.UUID, moderated moderation.Result) error {
+	if !moderated.Flagged {
+		return nil
+	}
+	for _, reason := range moderated.Reasons {
+		_, err := queries.CreateChirpFlag(ctx, database.CreateChirpFlagParams{
+			ChirpID: chirpID,
+			Reason:  reason,
+		})
+		if err != nil {
+			return err
+		}
 	}
+	return nil
+}
+
+// Respond with the reason a chirp was rejected by moderation
+func respondModerationRejected(w http.ResponseWriter, reason string) {
+	respondWithProblem(w, newProblem(http.StatusUnprocessableEntity, "chirp_rejected", reason).
+		withFieldErrors(FieldError{Field: "body", Code: "rejected", Message: reason}))
+}
 
-	authorID := uuid.Nil
-	authorIDString := r.URL.Query().Get("author_id")
+// Respond to a chirp body over the length limit
+func respondChirpTooLong(w http.ResponseWriter) {
+	detail := fmt.Sprintf("Chirp is longer than %d characters", maxChirpLength)
+	respondWithProblem(w, newProblem(http.StatusBadRequest, "chirp_too_long", detail).
+		with("limit", maxChirpLength).
+		withFieldErrors(FieldError{Field: "body", Code: "too_long", Message: detail, Limit: maxChirpLength}))
+}
+
+// Get Chirps
+func (cfg *apiConfig) handlerGetChirps(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	authorID := uuid.NullUUID{}
+	authorIDString := query.Get("author_id")
 	if authorIDString != "" {
-		authorID, err = uuid.Parse(authorIDString)
+		id, err := uuid.Parse(authorIDString)
 		if err != nil {
 			log.Printf("Invalid author ID: %s\n", err)
-			w.WriteHeader(http.StatusBadRequest)
+			respondWithFieldError(w, http.StatusBadRequest, "invalid_author_id", "author_id", "author_id must be a valid uuid")
 			return
 		}
+		authorID = uuid.NullUUID{UUID: id, Valid: true}
 	}
 
-	chirps := []Chirp{}
-	for _, dbChirp := range dbChirps {
-		log.Println(authorIDString)
-		if authorID != uuid.Nil && dbChirp.UserID != authorID {
-			continue
-		}
-		chirps = append(chirps, Chirp{
-			ID:        dbChirp.ID,
-			CreatedAt: dbChirp.CreatedAt,
-			UpdatedAt: dbChirp.UpdatedAt,
-			Body:      dbChirp.Body,
-			UserID:    dbChirp.UserID,
-		})
+	limit, err := parsePageLimit(query.Get("limit"))
+	if err != nil {
+		log.Printf("Invalid limit: %s\n", err)
+		respondWithFieldError(w, http.StatusBadRequest, "invalid_limit", "limit", "limit must be a positive integer")
+		return
 	}
 
-	sortString := r.URL.Query().Get("sort")
-	if sortString == "asc" || sortString == "" {
-		sort.Slice(chirps, func(i, j int) bool { return chirps[i].CreatedAt.Before(chirps[j].CreatedAt) })
+	cursorCreatedAt, cursorID, err := parseCursorParam(query.Get("cursor"))
+	if err != nil {
+		log.Printf("Invalid cursor: %s\n", err)
+		respondWithFieldError(w, http.StatusBadRequest, "invalid_cursor", "cursor", "cursor must be a next_cursor value from a previous response")
+		return
 	}
-	if sortString == "desc" {
-		sort.Slice(chirps, func(i, j int) bool { return chirps[i].CreatedAt.After(chirps[j].CreatedAt) })
+
+	//fetch one extra row to find out if there is a next page
+	var dbChirps []database.Chirp
+	if query.Get("sort") == "desc" {
+		dbChirps, err = cfg.queries.ListChirpsDesc(r.Context(), database.ListChirpsDescParams{
+			AuthorID:        authorID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			Limit:           int32(limit + 1),
+		})
+	} else {
+		dbChirps, err = cfg.queries.ListChirpsAsc(r.Context(), database.ListChirpsAscParams{
+			AuthorID:        authorID,
+			CursorCreatedAt: cursorCreatedAt,
+			CursorID:        cursorID,
+			Limit:           int32(limit + 1),
+		})
+	}
+	if err != nil {
+		log.Printf("Error getting chirps: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	page := newChirpsPage(dbChirps, limit)
+	err = cfg.enrichChirps(r.Context(), optionalUserID(r), page.chirpRefs())
+	if err != nil {
+		log.Printf("Couldn't enrich chirps: %s\n", err)
+		respondWithInternalError(w)
+		return
 	}
 
-	data, err := json.Marshal(chirps)
+	data, err := json.Marshal(page)
 	if err != nil {
 		log.Printf("Error marshalling JSON: %s\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondWithInternalError(w)
 		return
 	}
 
@@ -160,29 +352,35 @@ func (cfg *apiConfig) handlerGetChirpById(w http.ResponseWriter, r *http.Request
 	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
 	if err != nil {
 		log.Printf("Invalid chirpd ID\n")
-		w.WriteHeader(http.StatusBadRequest)
+		respondWithError(w, http.StatusBadRequest, "invalid_chirp_id", "Chirp ID must be a valid uuid")
 		return
 	}
 
 	dbChirp, err := cfg.queries.GetChirp(r.Context(), chirpID)
 	if err != nil {
 		log.Printf("Couldn't get chirp: %s\n", err)
-		w.WriteHeader(http.StatusNotFound)
+		respondWithError(w, http.StatusNotFound, "chirp_not_found", "Chirp not found")
 		return
 	}
 
-	chirp := Chirp{
-		ID:        dbChirp.ID,
-		CreatedAt: dbChirp.CreatedAt,
-		UpdatedAt: dbChirp.UpdatedAt,
-		Body:      dbChirp.Body,
-		UserID:    dbChirp.UserID,
+	if dbChirp.DeletedAt.Valid {
+		log.Printf("Chirp has been deleted\n")
+		respondWithError(w, http.StatusNotFound, "chirp_not_found", "Chirp not found")
+		return
+	}
+
+	chirp := chirpFromDB(dbChirp)
+	err = cfg.enrichChirps(r.Context(), optionalUserID(r), []*Chirp{&chirp})
+	if err != nil {
+		log.Printf("Couldn't enrich chirps: %s\n", err)
+		respondWithInternalError(w)
+		return
 	}
 
 	data, err := json.Marshal(chirp)
 	if err != nil {
 		log.Printf("Error marhsalling json: %s\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondWithInternalError(w)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -196,43 +394,298 @@ func (cfg *apiConfig) handlerDeleteChirpById(w http.ResponseWriter, r *http.Requ
 	chirpID, err := uuid.Parse(chirpIDString)
 	if err != nil {
 		log.Printf("Invalid chirp ID: %s\n", err)
-		w.WriteHeader(http.StatusBadRequest)
+		respondWithError(w, http.StatusBadRequest, "invalid_chirp_id", "Chirp ID must be a valid uuid")
 		return
 	}
 
-	token, err := auth.GetBearerToken(r.Header)
+	userID := requestUserID(r)
+
+	//lock the chirp so no reply can be added while deciding how to delete it
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
 	if err != nil {
-		log.Printf("Couldn't find access token: %s\n", err)
-		w.WriteHeader(http.StatusUnauthorized)
+		log.Printf("Error starting transaction: %s\n", err)
+		respondWithInternalError(w)
 		return
 	}
+	defer tx.Rollback()
+	qtx := cfg.queries.WithTx(tx)
 
-	userID, err := auth.ValidateJWT(token, cfg.secret)
+	dbChirp, err := qtx.GetChirpForUpdate(r.Context(), chirpID)
+	if err != nil || dbChirp.DeletedAt.Valid {
+		log.Printf("Couldn't find chirp: %s\n", err)
+		respondWithError(w, http.StatusNotFound, "chirp_not_found", "Chirp not found")
+		return
+	}
+
+	if dbChirp.UserID != userID {
+		log.Printf("You cannot delete this chirp: %s\n", err)
+		respondWithError(w, http.StatusForbidden, "not_chirp_owner", "Only the author can delete this chirp")
+		return
+	}
+
+	//media rows go with the chirp either way, their files are removed after commit
+	blobKeys, err := qtx.DeleteChirpMedia(r.Context(), uuid.NullUUID{UUID: chirpID, Valid: true})
 	if err != nil {
-		log.Printf("Could't validate access token: %s\n", err)
-		w.WriteHeader(http.StatusUnauthorized)
+		log.Printf("Couldn't delete chirp media: %s\n", err)
+		respondWithInternalError(w)
 		return
 	}
 
-	dbChirp, err := cfg.queries.GetChirp(r.Context(), chirpID)
+	//chirps with replies become tombstones so the rest of the thread survives
+	hasReplies, err := qtx.ChirpHasReplies(r.Context(), uuid.NullUUID{UUID: chirpID, Valid: true})
 	if err != nil {
+		log.Printf("Couldn't check chirp replies: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	if hasReplies {
+		err = qtx.TombstoneChirp(r.Context(), chirpID)
+		if err == nil {
+			err = qtx.DeleteChirpRevisions(r.Context(), chirpID)
+		}
+		if err == nil {
+			err = saveChirpEntities(r.Context(), qtx, chirpID, "")
+		}
+	} else {
+		err = qtx.DeleteChirpById(r.Context(), chirpID)
+		if err == nil {
+			err = removeOrphanedTombstones(r.Context(), qtx, dbChirp.InReplyTo)
+		}
+	}
+	if err != nil {
+		log.Printf("Couldn't delete chirp: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	err = enqueueWebhookEvent(r.Context(), qtx, webhooks.EventChirpDeleted, map[string]uuid.UUID{"id": chirpID, "user_id": userID})
+	if err != nil {
+		log.Printf("Couldn't queue chirp webhooks: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("Error committing chirp delete: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	cfg.deleteBlobs(r.Context(), blobKeys)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Delete tombstones whose last reply is gone, working up the thread until a chirp is still needed
+func removeOrphanedTombstones(ctx context.Context, q *database.Queries, parentID uuid.NullUUID) error {
+	for parentID.Valid {
+		parent, err := q.GetChirpForUpdate(ctx, parentID.UUID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !parent.DeletedAt.Valid {
+			return nil
+		}
+
+		hasReplies, err := q.ChirpHasReplies(ctx, uuid.NullUUID{UUID: parent.ID, Valid: true})
+		if err != nil {
+			return err
+		}
+		if hasReplies {
+			return nil
+		}
+
+		err = q.DeleteChirpById(ctx, parent.ID)
+		if err != nil {
+			return err
+		}
+		parentID = parent.InReplyTo
+	}
+	return nil
+}
+
+// Update Chirp
+func (cfg *apiConfig) handlerUpdateChirp(w http.ResponseWriter, r *http.Request) {
+	type chirpParams struct {
+		Body string `json:"body"`
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		log.Printf("Invalid chirp ID: %s\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid_chirp_id", "Chirp ID must be a valid uuid")
+		return
+	}
+
+	userID := requestUserID(r)
+
+	decoder := json.NewDecoder(r.Body)
+	chirp := chirpParams{}
+	err = decoder.Decode(&chirp)
+	if err != nil {
+		log.Printf("Error decoding json: %s\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid_json", "Request body is not valid JSON")
+		return
+	}
+
+	//same length and moderation rules as posting a chirp
+	if utf8.RuneCountInString(chirp.Body) > maxChirpLength {
+		log.Printf("Chirp exceeds 140 characters")
+		respondChirpTooLong(w)
+		return
+	}
+
+	moderated := cfg.moderator.Moderate(chirp.Body)
+	if moderated.Rejected {
+		log.Printf("Chirp rejected by moderation: %s\n", moderated.Reasons[0])
+		respondModerationRejected(w, moderated.Reasons[0])
+		return
+	}
+
+	//lock the chirp so concurrent edits can't lose a revision
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.queries.WithTx(tx)
+
+	dbChirp, err := qtx.GetChirpForUpdate(r.Context(), chirpID)
+	if err != nil || dbChirp.DeletedAt.Valid {
 		log.Printf("Couldn't find chirp: %s\n", err)
-		w.WriteHeader(http.StatusNotFound)
+		respondWithError(w, http.StatusNotFound, "chirp_not_found", "Chirp not found")
 		return
 	}
 
 	if dbChirp.UserID != userID {
-		log.Printf("You cannot delete this chirp: %s\n", err)
-		w.WriteHeader(http.StatusForbidden)
+		log.Printf("You cannot edit this chirp\n")
+		respondWithError(w, http.StatusForbidden, "not_chirp_owner", "Only the author can edit this chirp")
 		return
 	}
 
-	err = cfg.queries.DeleteChirpById(r.Context(), chirpID)
+	//keep the body being replaced as a revision
+	_, err = qtx.CreateChirpRevision(r.Context(), database.CreateChirpRevisionParams{
+		CreatedAt: dbChirp.UpdatedAt,
+		ChirpID:   dbChirp.ID,
+		Body:      dbChirp.Body,
+	})
 	if err != nil {
-		log.Printf("Couldn't delete chirp: %s\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		log.Printf("Couldn't save chirp revision: %s\n", err)
+		respondWithInternalError(w)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	updated, err := qtx.UpdateChirpBody(r.Context(), database.UpdateChirpBodyParams{
+		Body: moderated.Body,
+		ID:   dbChirp.ID,
+	})
+	if err != nil {
+		log.Printf("Couldn't update chirp: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	err = flagChirp(r.Context(), qtx, updated.ID, moderated)
+	if err != nil {
+		log.Printf("Error flagging chirp: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	err = saveChirpEntities(r.Context(), qtx, updated.ID, updated.Body)
+	if err != nil {
+		log.Printf("Error saving chirp hashtags and mentions: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("Error committing chirp update: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	resp := chirpFromDB(updated)
+	err = cfg.enrichChirps(r.Context(), uuid.NullUUID{UUID: userID, Valid: true}, []*Chirp{&resp})
+	if err != nil {
+		log.Printf("Couldn't enrich chirps: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// Get Chirp Revisions, only the author sees what they edited out
+func (cfg *apiConfig) handlerGetChirpRevisions(w http.ResponseWriter, r *http.Request) {
+	type Revision struct {
+		ID         uuid.UUID `json:"id"`
+		ChirpID    uuid.UUID `json:"chirp_id"`
+		Body       string    `json:"body"`
+		CreatedAt  time.Time `json:"created_at"`
+		ReplacedAt time.Time `json:"replaced_at"`
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		log.Printf("Invalid chirp ID: %s\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid_chirp_id", "Chirp ID must be a valid uuid")
+		return
+	}
+
+	dbChirp, err := cfg.queries.GetChirp(r.Context(), chirpID)
+	if err != nil || dbChirp.DeletedAt.Valid {
+		log.Printf("Couldn't find chirp: %s\n", err)
+		respondWithError(w, http.StatusNotFound, "chirp_not_found", "Chirp not found")
+		return
+	}
+
+	if dbChirp.UserID != requestUserID(r) {
+		log.Printf("Only the author can see revisions of chirp %s\n", chirpID)
+		respondWithError(w, http.StatusForbidden, "not_chirp_owner", "Only the author can see this chirp's revisions")
+		return
+	}
+
+	dbRevisions, err := cfg.queries.GetChirpRevisions(r.Context(), chirpID)
+	if err != nil {
+		log.Printf("Couldn't get chirp revisions: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	revisions := []Revision{}
+	for _, dbRevision := range dbRevisions {
+		revisions = append(revisions, Revision{
+			ID:         dbRevision.ID,
+			ChirpID:    dbRevision.ChirpID,
+			Body:       dbRevision.Body,
+			CreatedAt:  dbRevision.CreatedAt,
+			ReplacedAt: dbRevision.ReplacedAt,
+		})
+	}
+
+	data, err := json.Marshal(revisions)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
 }
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/skarsden/Chirp/internal/database"
+	"github.com/skarsden/Chirp/internal/entities"
+)
+
+// Hashtag or mention in a chirp body, offsets are in characters and end is exclusive
+type ChirpEntity struct {
+	Type   string     `json:"type"`
+	Start  int        `json:"start"`
+	End    int        `json:"end"`
+	Tag    string     `json:"tag,omitempty"`
+	UserID *uuid.UUID `json:"user_id,omitempty"`
+}
+
+// Hashtag entities can be read straight from the body, mentions need a user lookup
+func hashtagEntities(body string) []ChirpEntity {
+	found := []ChirpEntity{}
+	for _, entity := range entities.Parse(body) {
+		if entity.Type != entities.TypeHashtag {
+			continue
+		}
+		found = append(found, ChirpEntity{
+			Type:  entity.Type,
+			Start: entity.Start,
+			End:   entity.End,
+			Tag:   entity.Value,
+		})
+	}
+	return found
+}
+
+// Store hashtags and mentions for a chirp, replacing any from a previous body
+func saveChirpEntities(ctx context.Context, queries *database.Queries, chirpID uuid.UUID, body string) error {
+	err := queries.DeleteChirpHashtags(ctx, chirpID)
+	if err != nil {
+		return err
+	}
+	for _, tag := range entities.Hashtags(body) {
+		err = queries.CreateChirpHashtag(ctx, database.CreateChirpHashtagParams{
+			ChirpID: chirpID,
+			Tag:     tag,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	err = queries.DeleteChirpMentions(ctx, chirpID)
+	if err != nil {
+		return err
+	}
+	emails := entities.Mentions(body)
+	if len(emails) == 0 {
+		return nil
+	}
+
+	//mentions of emails that don't belong to a user are left as plain text
+	users, err := queries.GetUsersByEmails(ctx, emails)
+	if err != nil {
+		return err
+	}
+	for _, user := range users {
+		err = queries.CreateChirpMention(ctx, database.CreateChirpMentionParams{
+			ChirpID: chirpID,
+			UserID:  user.ID,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Add mention entities for mentions that were matched to a user when the chirp was saved
+func setMentionEntities(ctx context.Context, q *database.Queries, chirps []*Chirp) error {
+	if len(chirps) == 0 {
+		return nil
+	}
+	chirpIDs := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		chirpIDs = append(chirpIDs, chirp.ID)
+	}
+
+	mentions, err := q.GetChirpMentionUsers(ctx, chirpIDs)
+	if err != nil {
+		return err
+	}
+	mentionedUsers := map[uuid.UUID]map[string]uuid.UUID{}
+	for _, mention := range mentions {
+		if mentionedUsers[mention.ChirpID] == nil {
+			mentionedUsers[mention.ChirpID] = map[string]uuid.UUID{}
+		}
+		mentionedUsers[mention.ChirpID][strings.ToLower(mention.Email)] = mention.ID
+	}
+
+	for _, chirp := range chirps {
+		users, ok := mentionedUsers[chirp.ID]
+		if !ok {
+			continue
+		}
+		for _, entity := range entities.Parse(chirp.Body) {
+			userID, ok := users[entity.Value]
+			if entity.Type != entities.TypeMention || !ok {
+				continue
+			}
+			chirp.Entities = append(chirp.Entities, ChirpEntity{
+				Type:   entity.Type,
+				Start:  entity.Start,
+				End:    entity.End,
+				UserID: &userID,
+			})
+		}
+		sort.Slice(chirp.Entities, func(i, j int) bool { return chirp.Entities[i].Start < chirp.Entities[j].Start })
+	}
+	return nil
+}
+
+// Get Hashtag Chirps
+func (cfg *apiConfig) handlerGetHashtagChirps(w http.ResponseWriter, r *http.Request) {
+	tag := strings.ToLower(strings.TrimPrefix(r.PathValue("tag"), "#"))
+	if tag == "" {
+		log.Printf("Missing hashtag\n")
+		respondWithError(w, http.StatusBadRequest, "invalid_hashtag", "Hashtag must not be empty")
+		return
+	}
+
+	query := r.URL.Query()
+	limit, err := parsePageLimit(query.Get("limit"))
+	if err != nil {
+		log.Printf("Invalid limit: %s\n", err)
+		respondWithFieldError(w, http.StatusBadRequest, "invalid_limit", "limit", "limit must be a positive integer")
+		return
+	}
+
+	cursorCreatedAt, cursorID, err := parseCursorParam(query.Get("cursor"))
+	if err != nil {
+		log.Printf("Invalid cursor: %s\n", err)
+		respondWithFieldError(w, http.StatusBadRequest, "invalid_cursor", "cursor", "cursor must be a next_cursor value from a previous response")
+		return
+	}
+
+	//fetch one extra row to find out if there is a next page
+	dbChirps, err := cfg.queries.ListHashtagChirps(r.Context(), database.ListHashtagChirpsParams{
+		Tag:             tag,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		Limit:           int32(limit + 1),
+	})
+	if err != nil {
+		log.Printf("Error getting hashtag chirps: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	page := newChirpsPage(dbChirps, limit)
+	err = cfg.enrichChirps(r.Context(), optionalUserID(r), page.chirpRefs())
+	if err != nil {
+		log.Printf("Couldn't enrich chirps: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	data, err := json.Marshal(page)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// Get Mentions
+func (cfg *apiConfig) handlerGetMentions(w http.ResponseWriter, r *http.Request) {
+	userID := requestUserID(r)
+
+	query := r.URL.Query()
+	limit, err := parsePageLimit(query.Get("limit"))
+	if err != nil {
+		log.Printf("Invalid limit: %s\n", err)
+		respondWithFieldError(w, http.StatusBadRequest, "invalid_limit", "limit", "limit must be a positive integer")
+		return
+	}
+
+	cursorCreatedAt, cursorID, err := parseCursorParam(query.Get("cursor"))
+	if err != nil {
+		log.Printf("Invalid cursor: %s\n", err)
+		respondWithFieldError(w, http.StatusBadRequest, "invalid_cursor", "cursor", "cursor must be a next_cursor value from a previous response")
+		return
+	}
+
+	//fetch one extra row to find out if there is a next page
+	dbChirps, err := cfg.queries.ListMentionChirps(r.Context(), database.ListMentionChirpsParams{
+		UserID:          userID,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		Limit:           int32(limit + 1),
+	})
+	if err != nil {
+		log.Printf("Error getting mentions: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	page := newChirpsPage(dbChirps, limit)
+	err = cfg.enrichChirps(r.Context(), uuid.NullUUID{UUID: userID, Valid: true}, page.chirpRefs())
+	if err != nil {
+		log.Printf("Couldn't enrich chirps: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	data, err := json.Marshal(page)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/skarsden/Chirp/internal/database"
+)
+
+// Follow User
+func (cfg *apiConfig) handlerFollowUser(w http.ResponseWriter, r *http.Request) {
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		log.Printf("Invalid user ID: %s\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid_user_id", "User ID must be a valid uuid")
+		return
+	}
+
+	userID := requestUserID(r)
+
+	if followeeID == userID {
+		log.Printf("Users cannot follow themselves\n")
+		respondWithError(w, http.StatusBadRequest, "cannot_follow_self", "Users cannot follow themselves")
+		return
+	}
+
+	_, err = cfg.queries.GetUserByID(r.Context(), followeeID)
+	if err != nil {
+		log.Printf("Couldn't find user: %s\n", err)
+		respondWithError(w, http.StatusNotFound, "user_not_found", "User not found")
+		return
+	}
+
+	err = cfg.queries.CreateFollow(r.Context(), database.CreateFollowParams{
+		FollowerID: userID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		log.Printf("Couldn't follow user: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Unfollow User
+func (cfg *apiConfig) handlerUnfollowUser(w http.ResponseWriter, r *http.Request) {
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		log.Printf("Invalid user ID: %s\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid_user_id", "User ID must be a valid uuid")
+		return
+	}
+
+	userID := requestUserID(r)
+
+	err = cfg.queries.DeleteFollow(r.Context(), database.DeleteFollowParams{
+		FollowerID: userID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		log.Printf("Couldn't unfollow user: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Get Timeline
+func (cfg *apiConfig) handlerGetTimeline(w http.ResponseWriter, r *http.Request) {
+	userID := requestUserID(r)
+
+	query := r.URL.Query()
+	limit, err := parsePageLimit(query.Get("limit"))
+	if err != nil {
+		log.Printf("Invalid limit: %s\n", err)
+		respondWithFieldError(w, http.StatusBadRequest, "invalid_limit", "limit", "limit must be a positive integer")
+		return
+	}
+
+	cursorCreatedAt, cursorID, err := parseCursorParam(query.Get("cursor"))
+	if err != nil {
+		log.Printf("Invalid cursor: %s\n", err)
+		respondWithFieldError(w, http.StatusBadRequest, "invalid_cursor", "cursor", "cursor must be a next_cursor value from a previous response")
+		return
+	}
+
+	//fetch one extra row to find out if there is a next page
+	dbChirps, err := cfg.queries.ListTimelineChirps(r.Context(), database.ListTimelineChirpsParams{
+		FollowerID:      userID,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		Limit:           int32(limit + 1),
+	})
+	if err != nil {
+		log.Printf("Error getting timeline: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	page := newChirpsPage(dbChirps, limit)
+	err = cfg.enrichChirps(r.Context(), uuid.NullUUID{UUID: userID, Valid: true}, page.chirpRefs())
+	if err != nil {
+		log.Printf("Couldn't enrich chirps: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	data, err := json.Marshal(page)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
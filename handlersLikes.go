@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/skarsden/Chirp/internal/database"
+)
+
+// Fill in liked_by_me on chirps being returned to a signed in user
+func (cfg *apiConfig) setLikedByMe(ctx context.Context, userID uuid.UUID, chirps []*Chirp) error {
+	if len(chirps) == 0 {
+		return nil
+	}
+	chirpIDs := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		chirpIDs = append(chirpIDs, chirp.ID)
+	}
+
+	likedIDs, err := cfg.queries.GetLikedChirpIDs(ctx, database.GetLikedChirpIDsParams{
+		UserID:   userID,
+		ChirpIds: chirpIDs,
+	})
+	if err != nil {
+		return err
+	}
+	liked := make(map[uuid.UUID]bool, len(likedIDs))
+	for _, id := range likedIDs {
+		liked[id] = true
+	}
+
+	for _, chirp := range chirps {
+		likedByMe := liked[chirp.ID]
+		chirp.LikedByMe = &likedByMe
+	}
+	return nil
+}
+
+// Like Chirp
+func (cfg *apiConfig) handlerLikeChirp(w http.ResponseWriter, r *http.Request) {
+	cfg.handleChirpReaction(w, r, func(ctx context.Context, qtx *database.Queries, chirpID, userID uuid.UUID) error {
+		rows, err := qtx.CreateChirpLike(ctx, database.CreateChirpLikeParams{ChirpID: chirpID, UserID: userID})
+		if err != nil || rows == 0 {
+			return err
+		}
+		_, err = qtx.AdjustChirpLikeCount(ctx, database.AdjustChirpLikeCountParams{Delta: 1, ID: chirpID})
+		return err
+	})
+}
+
+// Unlike Chirp
+func (cfg *apiConfig) handlerUnlikeChirp(w http.ResponseWriter, r *http.Request) {
+	cfg.handleChirpReaction(w, r, func(ctx context.Context, qtx *database.Queries, chirpID, userID uuid.UUID) error {
+		rows, err := qtx.DeleteChirpLike(ctx, database.DeleteChirpLikeParams{ChirpID: chirpID, UserID: userID})
+		if err != nil || rows == 0 {
+			return err
+		}
+		_, err = qtx.AdjustChirpLikeCount(ctx, database.AdjustChirpLikeCountParams{Delta: -1, ID: chirpID})
+		return err
+	})
+}
+
+// Rechirp
+func (cfg *apiConfig) handlerRechirp(w http.ResponseWriter, r *http.Request) {
+	cfg.handleChirpReaction(w, r, func(ctx context.Context, qtx *database.Queries, chirpID, userID uuid.UUID) error {
+		rows, err := qtx.CreateRechirp(ctx, database.CreateRechirpParams{ChirpID: chirpID, UserID: userID})
+		if err != nil || rows == 0 {
+			return err
+		}
+		_, err = qtx.IncrementChirpRechirpCount(ctx, chirpID)
+		return err
+	})
+}
+
+// Shared flow for likes and rechirps. apply changes the per-user row and its counter
+// in one transaction, the counter is only touched when the row actually changed so
+// repeated or concurrent requests can't drift it.
+func (cfg *apiConfig) handleChirpReaction(w http.ResponseWriter, r *http.Request, apply func(context.Context, *database.Queries, uuid.UUID, uuid.UUID) error) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		log.Printf("Invalid chirp ID: %s\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid_chirp_id", "Chirp ID must be a valid uuid")
+		return
+	}
+
+	userID := requestUserID(r)
+
+	dbChirp, err := cfg.queries.GetChirp(r.Context(), chirpID)
+	if err != nil || dbChirp.DeletedAt.Valid {
+		log.Printf("Couldn't find chirp: %s\n", err)
+		respondWithError(w, http.StatusNotFound, "chirp_not_found", "Chirp not found")
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.queries.WithTx(tx)
+
+	err = apply(r.Context(), qtx, chirpID, userID)
+	if err != nil {
+		log.Printf("Couldn't update chirp reaction: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	dbChirp, err = qtx.GetChirp(r.Context(), chirpID)
+	if err != nil {
+		log.Printf("Couldn't get chirp: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("Error committing chirp reaction: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	chirp := chirpFromDB(dbChirp)
+	err = cfg.enrichChirps(r.Context(), uuid.NullUUID{UUID: userID, Valid: true}, []*Chirp{&chirp})
+	if err != nil {
+		log.Printf("Couldn't enrich chirps: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	data, err := json.Marshal(chirp)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skarsden/Chirp/internal/blobstore"
+	"github.com/skarsden/Chirp/internal/database"
+)
+
+// Uploaded image, attached to a chirp through media_ids
+type Media struct {
+	ID          uuid.UUID `json:"id"`
+	URL         string    `json:"url"`
+	ContentType string    `json:"content_type"`
+	SizeBytes   int64     `json:"size_bytes"`
+	Width       int32     `json:"width"`
+	Height      int32     `json:"height"`
+}
+
+func mediaFromDB(dbMedia database.Medium) Media {
+	return Media{
+		ID:          dbMedia.ID,
+		URL:         "/media/" + dbMedia.ID.String(),
+		ContentType: dbMedia.ContentType,
+		SizeBytes:   dbMedia.SizeBytes,
+		Width:       dbMedia.Width,
+		Height:      dbMedia.Height,
+	}
+}
+
+// Add attached media to chirps being returned
+func setChirpMedia(ctx context.Context, q *database.Queries, chirps []*Chirp) error {
+	if len(chirps) == 0 {
+		return nil
+	}
+	chirpIDs := make([]uuid.UUID, 0, len(chirps))
+	for _, chirp := range chirps {
+		chirpIDs = append(chirpIDs, chirp.ID)
+	}
+
+	dbMedia, err := q.GetChirpsMedia(ctx, chirpIDs)
+	if err != nil {
+		return err
+	}
+	media := map[uuid.UUID][]Media{}
+	for _, m := range dbMedia {
+		media[m.ChirpID.UUID] = append(media[m.ChirpID.UUID], mediaFromDB(m))
+	}
+
+	for _, chirp := range chirps {
+		if chirpMedia, ok := media[chirp.ID]; ok {
+			chirp.Media = chirpMedia
+		}
+	}
+	return nil
+}
+
+// Remove stored files once the media rows pointing at them are gone
+func (cfg *apiConfig) deleteBlobs(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		err := cfg.blobs.Delete(ctx, key)
+		if err != nil {
+			log.Printf("Couldn't delete media blob %s: %s\n", key, err)
+		}
+	}
+}
+
+// Delete uploads older than maxAge that were never attached to a chirp
+func (cfg *apiConfig) deleteUnattachedMedia(ctx context.Context, maxAge time.Duration) error {
+	keys, err := cfg.queries.DeleteUnattachedMedia(ctx, time.Now().UTC().Add(-maxAge))
+	if err != nil {
+		return err
+	}
+	if len(keys) > 0 {
+		log.Printf("Deleted %d unattached uploads\n", len(keys))
+	}
+	cfg.deleteBlobs(ctx, keys)
+	return nil
+}
+
+// Respond to an upload over the user's size quota
+func respondMediaTooLarge(w http.ResponseWriter, maxBytes int64) {
+	detail := fmt.Sprintf("Uploads are limited to %d bytes", maxBytes)
+	respondWithProblem(w, newProblem(http.StatusRequestEntityTooLarge, "media_too_large", detail).
+		with("limit", maxBytes).
+		withFieldErrors(FieldError{Field: "file", Code: "too_large", Message: detail, Limit: maxBytes}))
+}
+
+// Upload Media
+func (cfg *apiConfig) handlerUploadMedia(w http.ResponseWriter, r *http.Request) {
+	userID := requestUserID(r)
+
+	_, err := cfg.queries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		log.Printf("Couldn't find user: %s\n", err)
+		respondWithError(w, http.StatusUnauthorized, "user_not_found", "The user for this access token no longer exists")
+		return
+	}
+
+	isChirpyRed, err := cfg.queries.IsUserChirpyRed(r.Context(), userID)
+	if err != nil {
+		log.Printf("Couldn't get subscription: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	//Chirpy Red users get a bigger upload quota
+	maxBytes := cfg.mediaMaxBytes
+	if isChirpyRed {
+		maxBytes = cfg.mediaMaxBytesRed
+	}
+
+	//leave some room for the multipart headers around the file
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes+1<<20)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			log.Printf("Upload exceeds %d bytes\n", maxBytes)
+			respondMediaTooLarge(w, maxBytes)
+			return
+		}
+		log.Printf("Couldn't read uploaded file: %s\n", err)
+		respondWithFieldError(w, http.StatusBadRequest, "invalid_upload", "file", "Upload must be a multipart form with a file field")
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxBytes+1))
+	if err != nil {
+		log.Printf("Couldn't read uploaded file: %s\n", err)
+		respondWithFieldError(w, http.StatusBadRequest, "invalid_upload", "file", "Upload must be a multipart form with a file field")
+		return
+	}
+	if int64(len(data)) > maxBytes {
+		log.Printf("Upload exceeds %d bytes\n", maxBytes)
+		respondMediaTooLarge(w, maxBytes)
+		return
+	}
+
+	//only images we can decode are accepted, which also gives us the dimensions
+	imageConfig, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		log.Printf("Unsupported media type: %s\n", err)
+		respondWithError(w, http.StatusUnsupportedMediaType, "unsupported_media_type", "Only png, jpeg and gif images can be uploaded")
+		return
+	}
+
+	mediaID := uuid.New()
+	blobKey := mediaID.String()
+	err = cfg.blobs.Put(r.Context(), blobKey, bytes.NewReader(data))
+	if err != nil {
+		log.Printf("Couldn't store media: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	dbMedia, err := cfg.queries.CreateMedia(r.Context(), database.CreateMediaParams{
+		ID:          mediaID,
+		UserID:      userID,
+		BlobKey:     blobKey,
+		ContentType: "image/" + format,
+		SizeBytes:   int64(len(data)),
+		Width:       int32(imageConfig.Width),
+		Height:      int32(imageConfig.Height),
+	})
+	if err != nil {
+		log.Printf("Couldn't save media: %s\n", err)
+		cfg.deleteBlobs(r.Context(), []string{blobKey})
+		respondWithInternalError(w)
+		return
+	}
+
+	resp, err := json.Marshal(mediaFromDB(dbMedia))
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(resp)
+}
+
+// Serve Media
+func (cfg *apiConfig) handlerServeMedia(w http.ResponseWriter, r *http.Request) {
+	mediaID, err := uuid.Parse(r.PathValue("mediaID"))
+	if err != nil {
+		log.Printf("Invalid media ID: %s\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid_media_id", "Media ID must be a valid uuid")
+		return
+	}
+
+	//only media on a chirp is public, so uploads can't be handed out as links on their own
+	dbMedia, err := cfg.queries.GetVisibleMedia(r.Context(), mediaID)
+	if err != nil {
+		log.Printf("Couldn't find media: %s\n", err)
+		respondWithError(w, http.StatusNotFound, "media_not_found", "Media not found")
+		return
+	}
+
+	file, err := cfg.blobs.Open(r.Context(), dbMedia.BlobKey)
+	if errors.Is(err, blobstore.ErrNotFound) {
+		log.Printf("Media blob is missing: %s\n", dbMedia.BlobKey)
+		respondWithError(w, http.StatusNotFound, "media_not_found", "Media not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Couldn't open media: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", dbMedia.ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, "", dbMedia.CreatedAt, file)
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/skarsden/Chirp/internal/auth"
+	"github.com/skarsden/Chirp/internal/database"
+	"github.com/skarsden/Chirp/internal/oidc"
+)
+
+// How long the user has to sign in at the provider and come back
+const oidcLoginLifetime = 10 * time.Minute
+
+// Cookie tying a login to the browser that started it, so nobody can finish their own login in someone else's
+const oidcStateCookie = "oidc_state"
+
+// Start OIDC Login, sends the user to the provider
+func (cfg *apiConfig) handlerStartOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	state, err := auth.MakeOneTimeToken()
+	if err != nil {
+		log.Printf("Couldn't make oidc state: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	nonce, err := auth.MakeOneTimeToken()
+	if err != nil {
+		log.Printf("Couldn't make oidc nonce: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	verifier, err := auth.MakeOneTimeToken()
+	if err != nil {
+		log.Printf("Couldn't make pkce verifier: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	err = cfg.queries.CreateOIDCLogin(r.Context(), database.CreateOIDCLoginParams{
+		StateHash:    auth.HashToken(state),
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		ExpiresAt:    time.Now().UTC().Add(oidcLoginLifetime),
+	})
+	if err != nil {
+		log.Printf("Couldn't save oidc login: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/api/login/oidc",
+		MaxAge:   int(oidcLoginLifetime.Seconds()),
+		HttpOnly: true,
+		Secure:   cfg.platform != "dev",
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, cfg.oidc.AuthCodeURL(state, nonce, verifier), http.StatusFound)
+}
+
+// OIDC Callback, the provider sends the user back here with a code to exchange for their identity
+func (cfg *apiConfig) handlerOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if providerErr := query.Get("error"); providerErr != "" {
+		log.Printf("OIDC provider refused login: %s: %s\n", providerErr, query.Get("error_description"))
+		respondWithError(w, http.StatusUnauthorized, "oidc_login_failed", "Sign in with the provider was cancelled or refused")
+		return
+	}
+
+	//the login only counts in the browser it was started from
+	state := query.Get("state")
+	cookie, err := r.Cookie(oidcStateCookie)
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookie, Path: "/api/login/oidc", MaxAge: -1})
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+		log.Printf("OIDC state doesn't match this browser's\n")
+		respondWithFieldError(w, http.StatusBadRequest, "invalid_state", "state", "Login is invalid or expired, sign in again")
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.queries.WithTx(tx)
+
+	login, err := qtx.GetOIDCLoginForUpdate(r.Context(), auth.HashToken(state))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Couldn't get oidc login: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	if err != nil || login.UsedAt.Valid || !login.ExpiresAt.After(time.Now().UTC()) {
+		log.Printf("Invalid, used or expired oidc state\n")
+		respondWithFieldError(w, http.StatusBadRequest, "invalid_state", "state", "Login is invalid or expired, sign in again")
+		return
+	}
+
+	err = qtx.UseOIDCLogin(r.Context(), login.StateHash)
+	if err != nil {
+		log.Printf("Couldn't use oidc login: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	//the login is used up before calling the provider, so the row isn't locked while waiting on it
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("Error committing transaction: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	claims, err := cfg.oidc.Exchange(r.Context(), query.Get("code"), login.CodeVerifier, login.Nonce)
+	if err != nil {
+		log.Printf("Couldn't exchange oidc code: %s\n", err)
+		respondWithError(w, http.StatusUnauthorized, "oidc_login_failed", "Couldn't confirm your identity with the provider")
+		return
+	}
+
+	tx, err = cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	defer tx.Rollback()
+	qtx = cfg.queries.WithTx(tx)
+
+	dbUser, ok := cfg.oidcUser(w, r, qtx, claims)
+	if !ok {
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("Error committing transaction: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	cfg.finishLogin(w, r, dbUser, nil)
+}
+
+// Find the user linked to the provider account, linking or creating one by email the first time
+func (cfg *apiConfig) oidcUser(w http.ResponseWriter, r *http.Request, qtx *database.Queries, claims *oidc.IDClaims) (database.User, bool) {
+	identity, err := qtx.GetUserIdentity(r.Context(), database.GetUserIdentityParams{
+		Issuer:  cfg.oidc.Issuer(),
+		Subject: claims.Subject,
+	})
+	if err == nil {
+		dbUser, err := qtx.GetUserByID(r.Context(), identity.UserID)
+		if err != nil {
+			log.Printf("Couldn't get user: %s\n", err)
+			respondWithInternalError(w)
+			return database.User{}, false
+		}
+		return dbUser, true
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Couldn't get identity: %s\n", err)
+		respondWithInternalError(w)
+		return database.User{}, false
+	}
+
+	//only an address the provider has checked can be matched to an account
+	claims.Email = normalizeEmail(claims.Email)
+	if !claims.EmailVerified || !validEmail(claims.Email) {
+		log.Printf("OIDC subject %s has no verified email\n", claims.Subject)
+		respondWithError(w, http.StatusForbidden, "email_not_verified", "Your account at the provider needs a verified email address")
+		return database.User{}, false
+	}
+
+	dbUser, err := qtx.GetUserByEmail(r.Context(), claims.Email)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		//accounts created here have no password until the user resets one
+		dbUser, err = qtx.CreateUser(r.Context(), database.CreateUserParams{
+			Email:          claims.Email,
+			HashedPassword: unsetPassword,
+		})
+		if isUniqueViolation(err) {
+			log.Printf("Email was taken while signing in: %s\n", err)
+			respondWithError(w, http.StatusConflict, "email_taken", "Another account uses that email address")
+			return database.User{}, false
+		}
+		if err != nil {
+			log.Printf("Error creating user: %s\n", err)
+			respondWithInternalError(w)
+			return database.User{}, false
+		}
+	case err != nil:
+		log.Printf("Couldn't get user: %s\n", err)
+		respondWithInternalError(w)
+		return database.User{}, false
+	case !dbUser.EmailVerifiedAt.Valid:
+		//whoever registered the unconfirmed account may not own the address, so their password goes
+		err = qtx.SetUserHashedPassword(r.Context(), database.SetUserHashedPasswordParams{
+			ID:             dbUser.ID,
+			HashedPassword: unsetPassword,
+		})
+		if err != nil {
+			log.Printf("Couldn't clear password: %s\n", err)
+			respondWithInternalError(w)
+			return database.User{}, false
+		}
+	}
+
+	if !dbUser.EmailVerifiedAt.Valid {
+		dbUser, err = qtx.VerifyUserEmail(r.Context(), database.VerifyUserEmailParams{
+			ID:    dbUser.ID,
+			Email: dbUser.Email,
+		})
+		if err != nil {
+			log.Printf("Couldn't verify email: %s\n", err)
+			respondWithInternalError(w)
+			return database.User{}, false
+		}
+	}
+
+	err = qtx.CreateUserIdentity(r.Context(), database.CreateUserIdentityParams{
+		Issuer:  cfg.oidc.Issuer(),
+		Subject: claims.Subject,
+		UserID:  dbUser.ID,
+		Email:   claims.Email,
+	})
+	if err != nil {
+		log.Printf("Couldn't link identity: %s\n", err)
+		respondWithInternalError(w)
+		return database.User{}, false
+	}
+
+	return dbUser, true
+}
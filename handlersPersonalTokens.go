@@ -0,0 +1,197 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skarsden/Chirp/internal/auth"
+	"github.com/skarsden/Chirp/internal/database"
+)
+
+// Longest name a personal token can be given
+const personalTokenNameMaxLength = 100
+
+// A personal API token, the token itself is only shown once when it's created
+type PersonalToken struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	Token      string     `json:"token,omitempty"`
+}
+
+func personalTokenFromDB(row database.PersonalToken) PersonalToken {
+	token := PersonalToken{
+		ID:        row.ID,
+		Name:      row.Name,
+		Scopes:    strings.Fields(row.Scope),
+		CreatedAt: row.CreatedAt,
+	}
+	if row.LastUsedAt.Valid {
+		token.LastUsedAt = &row.LastUsedAt.Time
+	}
+	if row.ExpiresAt.Valid {
+		token.ExpiresAt = &row.ExpiresAt.Time
+	}
+	return token
+}
+
+// Personal tokens are managed from a signed in session, a leaked token shouldn't be able to mint more
+func requireSessionAuth(w http.ResponseWriter, r *http.Request) bool {
+	user, _ := authUserFromContext(r.Context())
+	if user.PersonalTokenID.Valid {
+		log.Printf("Personal token %s used to manage personal tokens\n", user.PersonalTokenID.UUID)
+		respondWithError(w, http.StatusForbidden, "session_required", "Sign in with your password to manage personal tokens")
+		return false
+	}
+	return true
+}
+
+// Create Personal Token
+func (cfg *apiConfig) handlerCreatePersonalToken(w http.ResponseWriter, r *http.Request) {
+	type reqParams struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days"`
+	}
+
+	if !requireSessionAuth(w, r) {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	req := reqParams{}
+	err := decoder.Decode(&req)
+	if err != nil {
+		log.Printf("Error decoding json: %s\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid_json", "Request body is not valid JSON")
+		return
+	}
+
+	fieldErrors := []FieldError{}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > personalTokenNameMaxLength {
+		fieldErrors = append(fieldErrors, FieldError{Field: "name", Code: "invalid", Message: "Name must be between 1 and 100 characters"})
+	}
+	if len(req.Scopes) == 0 {
+		fieldErrors = append(fieldErrors, FieldError{Field: "scopes", Code: "required", Message: "Token needs at least one scope"})
+	} else if err := auth.ValidateScopes(req.Scopes); err != nil {
+		fieldErrors = append(fieldErrors, FieldError{Field: "scopes", Code: "invalid", Message: "Scopes must be from " + strings.Join(auth.AllScopes, ", ")})
+	}
+	if req.ExpiresInDays < 0 {
+		fieldErrors = append(fieldErrors, FieldError{Field: "expires_in_days", Code: "invalid", Message: "Expiry can't be negative, leave it out for a token that doesn't expire"})
+	}
+	if len(fieldErrors) > 0 {
+		log.Printf("Invalid personal token request: %v\n", fieldErrors)
+		respondWithProblem(w, newProblem(http.StatusBadRequest, "invalid_personal_token", "Personal token request is invalid").
+			withFieldErrors(fieldErrors...))
+		return
+	}
+
+	token, err := auth.MakePersonalToken()
+	if err != nil {
+		log.Printf("Couldn't make personal token: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	expiresAt := sql.NullTime{}
+	if req.ExpiresInDays > 0 {
+		expiresAt = sql.NullTime{Time: time.Now().UTC().AddDate(0, 0, req.ExpiresInDays), Valid: true}
+	}
+
+	dbToken, err := cfg.queries.CreatePersonalToken(r.Context(), database.CreatePersonalTokenParams{
+		UserID:    requestUserID(r),
+		Name:      req.Name,
+		TokenHash: auth.HashToken(token),
+		Scope:     strings.Join(req.Scopes, " "),
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		log.Printf("Couldn't save personal token: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	resp := personalTokenFromDB(dbToken)
+	resp.Token = token
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("Error marshalling json: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(data)
+}
+
+// Get Personal Tokens
+func (cfg *apiConfig) handlerGetPersonalTokens(w http.ResponseWriter, r *http.Request) {
+	if !requireSessionAuth(w, r) {
+		return
+	}
+
+	dbTokens, err := cfg.queries.ListPersonalTokens(r.Context(), requestUserID(r))
+	if err != nil {
+		log.Printf("Couldn't get personal tokens: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	tokens := make([]PersonalToken, 0, len(dbTokens))
+	for _, dbToken := range dbTokens {
+		tokens = append(tokens, personalTokenFromDB(dbToken))
+	}
+
+	data, err := json.Marshal(tokens)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// Revoke Personal Token
+func (cfg *apiConfig) handlerRevokePersonalToken(w http.ResponseWriter, r *http.Request) {
+	tokenID, err := uuid.Parse(r.PathValue("tokenID"))
+	if err != nil {
+		log.Printf("Invalid token ID: %s\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid_token_id", "Token ID must be a valid uuid")
+		return
+	}
+
+	if !requireSessionAuth(w, r) {
+		return
+	}
+
+	userID := requestUserID(r)
+
+	rows, err := cfg.queries.RevokePersonalToken(r.Context(), database.RevokePersonalTokenParams{
+		ID:     tokenID,
+		UserID: userID,
+	})
+	if err != nil {
+		log.Printf("Couldn't revoke personal token: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	if rows == 0 {
+		log.Printf("No active personal token %s for user %s\n", tokenID, userID)
+		respondWithError(w, http.StatusNotFound, "personal_token_not_found", "Personal token not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skarsden/Chirp/internal/database"
+	"github.com/skarsden/Chirp/internal/search"
+)
+
+// Search Chirps
+func (cfg *apiConfig) handlerSearchChirps(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	tsQuery, err := search.BuildQuery(query.Get("q"))
+	if err != nil {
+		log.Printf("Invalid search query: %s\n", err)
+		respondWithFieldError(w, http.StatusBadRequest, "invalid_search_query", "q", "q must contain at least one word to search for")
+		return
+	}
+
+	authorID := uuid.NullUUID{}
+	authorIDString := query.Get("author_id")
+	if authorIDString != "" {
+		id, err := uuid.Parse(authorIDString)
+		if err != nil {
+			log.Printf("Invalid author ID: %s\n", err)
+			respondWithFieldError(w, http.StatusBadRequest, "invalid_author_id", "author_id", "author_id must be a valid uuid")
+			return
+		}
+		authorID = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	limit, err := parsePageLimit(query.Get("limit"))
+	if err != nil {
+		log.Printf("Invalid limit: %s\n", err)
+		respondWithFieldError(w, http.StatusBadRequest, "invalid_limit", "limit", "limit must be a positive integer")
+		return
+	}
+
+	//results are ordered by rank unless a date sort is asked for
+	sortString := query.Get("sort")
+	if sortString != "asc" && sortString != "desc" {
+		sortString = ""
+	}
+
+	cursor, err := parseSearchCursorParam(query.Get("cursor"))
+	if err != nil {
+		log.Printf("Invalid cursor: %s\n", err)
+		respondWithFieldError(w, http.StatusBadRequest, "invalid_cursor", "cursor", "cursor must be a next_cursor value from a previous response")
+		return
+	}
+
+	//fetch one extra row to find out if there is a next page
+	rows, err := cfg.queries.SearchChirps(r.Context(), database.SearchChirpsParams{
+		Query:           tsQuery,
+		AuthorID:        authorID,
+		CursorCreatedAt: cursor.createdAt,
+		Sort:            sortString,
+		CursorID:        cursor.id,
+		CursorRank:      cursor.rank,
+		Limit:           int32(limit + 1),
+	})
+	if err != nil {
+		log.Printf("Error searching chirps: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	page := ChirpsPage{Chirps: []Chirp{}}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		last := rows[len(rows)-1]
+		page.NextCursor = encodeSearchCursor(last.Rank, last.CreatedAt, last.ID)
+	}
+	for _, row := range rows {
+		page.Chirps = append(page.Chirps, chirpFromDB(database.Chirp{
+			ID:           row.ID,
+			CreatedAt:    row.CreatedAt,
+			UpdatedAt:    row.UpdatedAt,
+			Body:         row.Body,
+			UserID:       row.UserID,
+			InReplyTo:    row.InReplyTo,
+			DeletedAt:    row.DeletedAt,
+			LikeCount:    row.LikeCount,
+			RechirpCount: row.RechirpCount,
+		}))
+	}
+
+	err = cfg.enrichChirps(r.Context(), optionalUserID(r), page.chirpRefs())
+	if err != nil {
+		log.Printf("Couldn't enrich chirps: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	data, err := json.Marshal(page)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// Position of the last search result, ranked results also need its rank to continue from
+type searchCursor struct {
+	rank      sql.NullFloat64
+	createdAt sql.NullTime
+	id        uuid.NullUUID
+}
+
+// Encode search cursor as an opaque url-safe string, wrapping a regular page cursor
+func encodeSearchCursor(rank float32, createdAt time.Time, id uuid.UUID) string {
+	raw := strconv.FormatFloat(float64(rank), 'g', -1, 32) + "|" + encodeCursor(createdAt, id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// Parse cursor query param produced by encodeSearchCursor
+func parseSearchCursorParam(cursorString string) (searchCursor, error) {
+	if cursorString == "" {
+		return searchCursor{}, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(cursorString)
+	if err != nil {
+		return searchCursor{}, errors.New("malformed cursor")
+	}
+	rankString, pageCursorString, found := strings.Cut(string(data), "|")
+	if !found || pageCursorString == "" {
+		return searchCursor{}, errors.New("malformed cursor")
+	}
+	rank, err := strconv.ParseFloat(rankString, 32)
+	if err != nil {
+		return searchCursor{}, errors.New("malformed cursor rank")
+	}
+	createdAt, id, err := parseCursorParam(pageCursorString)
+	if err != nil {
+		return searchCursor{}, err
+	}
+	return searchCursor{rank: sql.NullFloat64{Float64: rank, Valid: true}, createdAt: createdAt, id: id}, nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skarsden/Chirp/internal/database"
+)
+
+// A signed in device, one per login. The id is the refresh token family, the token itself is never shown
+type Session struct {
+	ID         uuid.UUID `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	UserAgent  string    `json:"user_agent"`
+	IPAddress  string    `json:"ip_address"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+func sessionFromDB(row database.ListSessionsRow) Session {
+	return Session{
+		ID:         row.FamilyID,
+		CreatedAt:  row.CreatedAt,
+		LastUsedAt: row.LastUsedAt,
+		UserAgent:  row.UserAgent,
+		IPAddress:  row.IpAddress,
+		ExpiresAt:  row.ExpiresAt,
+	}
+}
+
+// IP address of the client, without the port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// Get Sessions
+func (cfg *apiConfig) handlerGetSessions(w http.ResponseWriter, r *http.Request) {
+	userID := requestUserID(r)
+
+	dbSessions, err := cfg.queries.ListSessions(r.Context(), userID)
+	if err != nil {
+		log.Printf("Couldn't get sessions: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	sessions := make([]Session, 0, len(dbSessions))
+	for _, dbSession := range dbSessions {
+		sessions = append(sessions, sessionFromDB(dbSession))
+	}
+
+	data, err := json.Marshal(sessions)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// Revoke Session
+func (cfg *apiConfig) handlerRevokeSession(w http.ResponseWriter, r *http.Request) {
+	sessionID, err := uuid.Parse(r.PathValue("sessionID"))
+	if err != nil {
+		log.Printf("Invalid session ID: %s\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid_session_id", "Session ID must be a valid uuid")
+		return
+	}
+
+	userID := requestUserID(r)
+
+	rows, err := cfg.queries.RevokeSession(r.Context(), database.RevokeSessionParams{
+		FamilyID: sessionID,
+		UserID:   userID,
+	})
+	if err != nil {
+		log.Printf("Couldn't revoke session: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	if rows == 0 {
+		log.Printf("No active session %s for user %s\n", sessionID, userID)
+		respondWithError(w, http.StatusNotFound, "session_not_found", "Session not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Revoke every session, logging the user out everywhere
+func (cfg *apiConfig) handlerRevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	userID := requestUserID(r)
+
+	_, err := cfg.queries.RevokeAllSessions(r.Context(), userID)
+	if err != nil {
+		log.Printf("Couldn't revoke sessions: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
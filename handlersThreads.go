@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/skarsden/Chirp/internal/database"
+)
+
+const (
+	defaultThreadDepth = 3
+	maxThreadDepth     = 10
+)
+
+// Chirp with its nested replies
+type ThreadNode struct {
+	Chirp
+	Replies []*ThreadNode `json:"replies"`
+}
+
+// Conversation around a chirp, ancestors are ordered from the root down
+type Thread struct {
+	Ancestors []Chirp     `json:"ancestors"`
+	Chirp     *ThreadNode `json:"chirp"`
+}
+
+// Pointers to every chirp in the thread, for filling in per-viewer fields
+func (thread Thread) chirpRefs() []*Chirp {
+	refs := []*Chirp{}
+	for i := range thread.Ancestors {
+		refs = append(refs, &thread.Ancestors[i])
+	}
+	nodes := []*ThreadNode{thread.Chirp}
+	for len(nodes) > 0 {
+		node := nodes[0]
+		nodes = append(nodes[1:], node.Replies...)
+		refs = append(refs, &node.Chirp)
+	}
+	return refs
+}
+
+// Nest replies under their parents, replies arrive oldest first so each level stays in order
+func buildThreadTree(root database.Chirp, replies []database.Chirp) *ThreadNode {
+	rootNode := &ThreadNode{Chirp: chirpFromDB(root), Replies: []*ThreadNode{}}
+	nodes := map[uuid.UUID]*ThreadNode{root.ID: rootNode}
+	for _, reply := range replies {
+		nodes[reply.ID] = &ThreadNode{Chirp: chirpFromDB(reply), Replies: []*ThreadNode{}}
+	}
+	for _, reply := range replies {
+		parent, ok := nodes[reply.InReplyTo.UUID]
+		if !ok {
+			continue
+		}
+		parent.Replies = append(parent.Replies, nodes[reply.ID])
+	}
+	return rootNode
+}
+
+// Get Chirp Thread
+func (cfg *apiConfig) handlerGetChirpThread(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		log.Printf("Invalid chirp ID: %s\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid_chirp_id", "Chirp ID must be a valid uuid")
+		return
+	}
+
+	depth := defaultThreadDepth
+	depthString := r.URL.Query().Get("depth")
+	if depthString != "" {
+		depth, err = strconv.Atoi(depthString)
+		if err != nil || depth < 0 {
+			log.Printf("Invalid thread depth: %s\n", depthString)
+			respondWithFieldError(w, http.StatusBadRequest, "invalid_depth", "depth", "depth must be a non-negative integer")
+			return
+		}
+		depth = min(depth, maxThreadDepth)
+	}
+
+	//tombstones are still returned so the shape of the conversation is kept
+	dbChirp, err := cfg.queries.GetChirp(r.Context(), chirpID)
+	if err != nil {
+		log.Printf("Couldn't get chirp: %s\n", err)
+		respondWithError(w, http.StatusNotFound, "chirp_not_found", "Chirp not found")
+		return
+	}
+
+	dbAncestors, err := cfg.queries.GetChirpAncestors(r.Context(), chirpID)
+	if err != nil {
+		log.Printf("Couldn't get chirp ancestors: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	dbReplies := []database.Chirp{}
+	if depth > 0 {
+		dbReplies, err = cfg.queries.GetChirpReplies(r.Context(), database.GetChirpRepliesParams{
+			ChirpID:  uuid.NullUUID{UUID: chirpID, Valid: true},
+			MaxDepth: int32(depth),
+		})
+		if err != nil {
+			log.Printf("Couldn't get chirp replies: %s\n", err)
+			respondWithInternalError(w)
+			return
+		}
+	}
+
+	thread := Thread{
+		Ancestors: []Chirp{},
+		Chirp:     buildThreadTree(dbChirp, dbReplies),
+	}
+	for _, ancestor := range dbAncestors {
+		thread.Ancestors = append(thread.Ancestors, chirpFromDB(ancestor))
+	}
+
+	err = cfg.enrichChirps(r.Context(), optionalUserID(r), thread.chirpRefs())
+	if err != nil {
+		log.Printf("Couldn't enrich chirps: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	data, err := json.Marshal(thread)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
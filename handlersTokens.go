@@ -1,46 +1,138 @@
 package main
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/skarsden/Chirp/internal/auth"
+	"github.com/skarsden/Chirp/internal/database"
 )
 
+// Refresh tokens last 60 days from when they were issued
+const refreshTokenLifetime = 60 * 24 * time.Hour
+
+// Make a refresh token and save it in family, noting the client it was issued to
+func issueRefreshToken(r *http.Request, q *database.Queries, userID, familyID uuid.UUID) (string, error) {
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	_, err = q.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
+		TokenHash: auth.HashToken(refreshToken),
+		UserID:    userID,
+		ExpiresAt: time.Now().UTC().Add(refreshTokenLifetime),
+		FamilyID:  familyID,
+		UserAgent: r.UserAgent(),
+		IpAddress: clientIP(r),
+	})
+	if err != nil {
+		return "", err
+	}
+	return refreshToken, nil
+}
+
 // Refresh Token
 func (cfg *apiConfig) handlerRefreshToken(w http.ResponseWriter, r *http.Request) {
 	type Response struct {
-		Token string `json:"token"`
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
 	}
 
 	refreshToken, err := auth.GetBearerToken(r.Header)
 	if err != nil {
 		log.Printf("Couldn't find refresh token: %s\n", err)
-		w.WriteHeader(http.StatusBadRequest)
+		respondWithError(w, http.StatusBadRequest, "missing_refresh_token", "Request is missing a bearer refresh token")
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.queries.WithTx(tx)
+
+	//lock the token so two refreshes with it can't both succeed
+	stored, err := qtx.GetRefreshTokenForUpdate(r.Context(), auth.HashToken(refreshToken))
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Unknown refresh token\n")
+		respondWithError(w, http.StatusUnauthorized, "invalid_refresh_token", "Refresh token is invalid, expired or revoked")
+		return
+	}
+	if err != nil {
+		log.Printf("Couldn't get refresh token: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	//a token that was already replaced has leaked, end the whole session
+	if stored.ReplacedAt.Valid {
+		log.Printf("Refresh token reused, revoking family %s\n", stored.FamilyID)
+		_, err = qtx.RevokeRefreshTokenFamily(r.Context(), stored.FamilyID)
+		if err != nil {
+			log.Printf("Couldn't revoke refresh token family: %s\n", err)
+			respondWithInternalError(w)
+			return
+		}
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("Error committing transaction: %s\n", err)
+			respondWithInternalError(w)
+			return
+		}
+		respondWithError(w, http.StatusUnauthorized, "refresh_token_reused", "Refresh token was already used, sign in again")
+		return
+	}
+
+	if stored.RevokedAt.Valid || !stored.ExpiresAt.After(time.Now().UTC()) {
+		log.Printf("Refresh token is revoked or expired\n")
+		respondWithError(w, http.StatusUnauthorized, "invalid_refresh_token", "Refresh token is invalid, expired or revoked")
 		return
 	}
 
-	user, err := cfg.queries.GetUserFromRefreshToken(r.Context(), refreshToken)
+	newRefreshToken, err := issueRefreshToken(r, qtx, stored.UserID, stored.FamilyID)
 	if err != nil {
-		log.Printf("Couldn't get user from refresh token: %s\n", err)
-		w.WriteHeader(http.StatusUnauthorized)
+		log.Printf("Couldn't save refresh token: %s\n", err)
+		respondWithInternalError(w)
 		return
 	}
 
-	accessToken, err := auth.MakeJWT(user.ID, cfg.secret, time.Hour)
+	_, err = qtx.ReplaceRefreshToken(r.Context(), database.ReplaceRefreshTokenParams{
+		TokenHash:  stored.TokenHash,
+		ReplacedBy: sql.NullString{String: auth.HashToken(newRefreshToken), Valid: true},
+	})
+	if err != nil {
+		log.Printf("Couldn't retire refresh token: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("Error committing transaction: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	accessToken, err := auth.MakeSessionJWT(stored.UserID, stored.FamilyID, cfg.jwtKeys, time.Hour)
 	if err != nil {
 		log.Printf("Coudln't validate token: %s\n", err)
-		w.WriteHeader(http.StatusUnauthorized)
+		respondWithInternalError(w)
 		return
 	}
 
-	resp := Response{Token: accessToken}
+	resp := Response{Token: accessToken, RefreshToken: newRefreshToken}
 	data, err := json.Marshal(resp)
 	if err != nil {
 		log.Printf("Error marshalling json: %s\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondWithInternalError(w)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -48,21 +140,48 @@ func (cfg *apiConfig) handlerRefreshToken(w http.ResponseWriter, r *http.Request
 	w.Write(data)
 }
 
+// Revoke Token, along with every other token in its family
 func (cfg *apiConfig) handlerRevokeToken(w http.ResponseWriter, r *http.Request) {
 	refreshToken, err := auth.GetBearerToken(r.Header)
 	if err != nil {
 		log.Printf("Couldn't find token: %s\n", err)
-		w.WriteHeader(http.StatusBadRequest)
+		respondWithError(w, http.StatusBadRequest, "missing_refresh_token", "Request is missing a bearer refresh token")
 		return
 	}
 
-	_, err = cfg.queries.RevokeRefreshToken(r.Context(), refreshToken)
+	stored, err := cfg.queries.RevokeRefreshToken(r.Context(), auth.HashToken(refreshToken))
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Unknown refresh token\n")
+		respondWithError(w, http.StatusUnauthorized, "invalid_refresh_token", "Refresh token is invalid, expired or revoked")
+		return
+	}
 	if err != nil {
 		log.Printf("Error revoking session: %s\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondWithInternalError(w)
 		return
+	}
 
+	_, err = cfg.queries.RevokeRefreshTokenFamily(r.Context(), stored.FamilyID)
+	if err != nil {
+		log.Printf("Error revoking session: %s\n", err)
+		respondWithInternalError(w)
+		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// JWKS, public keys other services use to verify our access tokens
+func (cfg *apiConfig) handlerJWKS(w http.ResponseWriter, r *http.Request) {
+	data, err := json.Marshal(cfg.jwtKeys.JWKS())
+	if err != nil {
+		log.Printf("Error marshalling JWKS: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	//short cache so verifiers pick up a rotation well within the grace window
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
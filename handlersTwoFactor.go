@@ -0,0 +1,436 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skarsden/Chirp/internal/auth"
+	"github.com/skarsden/Chirp/internal/database"
+	"github.com/skarsden/Chirp/internal/totp"
+)
+
+const (
+	// name shown for the account in authenticator apps
+	totpIssuer = "Chirpy"
+	// how long the second step of a login can take, and how many codes it can try
+	loginChallengeLifetime    = 5 * time.Minute
+	loginChallengeMaxAttempts = 5
+)
+
+// Check whether the user has confirmed two factor auth
+func (cfg *apiConfig) twoFactorEnabled(ctx context.Context, userID uuid.UUID) (bool, error) {
+	userTOTP, err := cfg.queries.GetUserTOTP(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return userTOTP.ConfirmedAt.Valid, nil
+}
+
+// Respond to a correct password with a challenge to send back with a TOTP or recovery code
+func (cfg *apiConfig) respondWithLoginChallenge(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	type Response struct {
+		TwoFactorRequired bool      `json:"two_factor_required"`
+		ChallengeToken    string    `json:"challenge_token"`
+		ExpiresAt         time.Time `json:"expires_at"`
+	}
+
+	token, err := auth.MakeOneTimeToken()
+	if err != nil {
+		log.Printf("Couldn't make challenge token: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	expiresAt := time.Now().UTC().Add(loginChallengeLifetime)
+	err = cfg.queries.CreateLoginChallenge(r.Context(), database.CreateLoginChallengeParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		log.Printf("Couldn't save challenge token: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	data, err := json.Marshal(Response{TwoFactorRequired: true, ChallengeToken: token, ExpiresAt: expiresAt})
+	if err != nil {
+		log.Printf("Error marshalling json: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// Enroll Two Factor, starts over with a new secret until it's confirmed
+func (cfg *apiConfig) handlerEnrollTwoFactor(w http.ResponseWriter, r *http.Request) {
+	type reqParams struct {
+		CurrentPassword string `json:"current_password"`
+	}
+
+	type Response struct {
+		Secret        string   `json:"secret"`
+		OTPAuthURI    string   `json:"otpauth_uri"`
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	req := reqParams{}
+	err := decoder.Decode(&req)
+	if err != nil {
+		log.Printf("Error decoding json: %s\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid_json", "Request body is not valid JSON")
+		return
+	}
+
+	userID := requestUserID(r)
+	user, err := cfg.queries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		log.Printf("Couldn't get user: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	if !cfg.checkCurrentPassword(w, r, user, req.CurrentPassword) {
+		return
+	}
+
+	enabled, err := cfg.twoFactorEnabled(r.Context(), userID)
+	if err != nil {
+		log.Printf("Couldn't check two factor auth: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	if enabled {
+		log.Printf("User %s already has two factor auth\n", userID)
+		respondWithError(w, http.StatusConflict, "two_factor_enabled", "Two factor auth is already set up")
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		log.Printf("Couldn't generate totp secret: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	encryptedSecret, err := cfg.secretBox.Seal(secret)
+	if err != nil {
+		log.Printf("Couldn't encrypt totp secret: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	recoveryCodes, err := auth.MakeRecoveryCodes()
+	if err != nil {
+		log.Printf("Couldn't make recovery codes: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	codeHashes := make([]string, 0, len(recoveryCodes))
+	for _, code := range recoveryCodes {
+		hash, err := auth.HashRecoveryCode(code)
+		if err != nil {
+			log.Printf("Couldn't hash recovery code: %s\n", err)
+			respondWithInternalError(w)
+			return
+		}
+		codeHashes = append(codeHashes, hash)
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.queries.WithTx(tx)
+
+	err = qtx.UpsertUserTOTP(r.Context(), database.UpsertUserTOTPParams{
+		UserID:          userID,
+		EncryptedSecret: encryptedSecret,
+	})
+	if err != nil {
+		log.Printf("Couldn't save totp secret: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	//codes from an earlier enrollment stop working
+	err = qtx.DeleteRecoveryCodes(r.Context(), userID)
+	if err != nil {
+		log.Printf("Couldn't delete recovery codes: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	for _, hash := range codeHashes {
+		err = qtx.CreateRecoveryCode(r.Context(), database.CreateRecoveryCodeParams{
+			UserID:   userID,
+			CodeHash: hash,
+		})
+		if err != nil {
+			log.Printf("Couldn't save recovery code: %s\n", err)
+			respondWithInternalError(w)
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("Error committing transaction: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	data, err := json.Marshal(Response{
+		Secret:        secret,
+		OTPAuthURI:    totp.URI(totpIssuer, user.Email, secret),
+		RecoveryCodes: recoveryCodes,
+	})
+	if err != nil {
+		log.Printf("Error marshalling json: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// Confirm Two Factor with a code from the authenticator app, turning it on
+func (cfg *apiConfig) handlerConfirmTwoFactor(w http.ResponseWriter, r *http.Request) {
+	type reqParams struct {
+		Code string `json:"code"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	req := reqParams{}
+	err := decoder.Decode(&req)
+	if err != nil {
+		log.Printf("Error decoding json: %s\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid_json", "Request body is not valid JSON")
+		return
+	}
+
+	userID := requestUserID(r)
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.queries.WithTx(tx)
+
+	userTOTP, err := qtx.GetUserTOTPForUpdate(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Printf("User %s hasn't enrolled in two factor auth\n", userID)
+		respondWithError(w, http.StatusConflict, "two_factor_not_enrolled", "Enroll in two factor auth first")
+		return
+	}
+	if err != nil {
+		log.Printf("Couldn't get totp secret: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	if userTOTP.ConfirmedAt.Valid {
+		log.Printf("User %s already has two factor auth\n", userID)
+		respondWithError(w, http.StatusConflict, "two_factor_enabled", "Two factor auth is already set up")
+		return
+	}
+
+	secret, err := cfg.secretBox.Open(userTOTP.EncryptedSecret)
+	if err != nil {
+		log.Printf("Couldn't decrypt totp secret: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	step, ok := totp.Validate(secret, req.Code, time.Now(), userTOTP.LastUsedStep)
+	if !ok {
+		log.Printf("Invalid totp code for user %s\n", userID)
+		respondWithFieldError(w, http.StatusBadRequest, "invalid_code", "code", "Code is incorrect or expired")
+		return
+	}
+
+	err = qtx.ConfirmUserTOTP(r.Context(), database.ConfirmUserTOTPParams{
+		UserID:       userID,
+		LastUsedStep: step,
+	})
+	if err != nil {
+		log.Printf("Couldn't confirm two factor auth: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("Error committing transaction: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Login Two Factor, the second step of a login with either a TOTP code or a recovery code
+func (cfg *apiConfig) handlerLoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	type reqParams struct {
+		ChallengeToken string `json:"challenge_token"`
+		Code           string `json:"code"`
+		RecoveryCode   string `json:"recovery_code"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	req := reqParams{}
+	err := decoder.Decode(&req)
+	if err != nil {
+		log.Printf("Error decoding json: %s\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid_json", "Request body is not valid JSON")
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.queries.WithTx(tx)
+
+	challenge, err := qtx.GetLoginChallengeForUpdate(r.Context(), auth.HashToken(req.ChallengeToken))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Couldn't get login challenge: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	if err != nil || challenge.UsedAt.Valid || !challenge.ExpiresAt.After(time.Now().UTC()) || challenge.Attempts >= loginChallengeMaxAttempts {
+		log.Printf("Invalid, used or expired login challenge\n")
+		respondWithFieldError(w, http.StatusUnauthorized, "invalid_challenge", "challenge_token", "Login challenge is invalid or expired, sign in again")
+		return
+	}
+
+	dbUser, err := qtx.GetUserByID(r.Context(), challenge.UserID)
+	if err != nil {
+		log.Printf("Couldn't get user: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	//codes count against the same lockouts as passwords, a fresh challenge doesn't give more guesses
+	lockouts := cfg.loginLockouts(r, dbUser.Email)
+	wait, err := attemptLoginLockouts(r.Context(), lockouts)
+	if err != nil {
+		log.Printf("Couldn't check login lockout: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	if wait > 0 {
+		log.Printf("Two factor login locked out for %s\n", wait)
+		respondTooManyAttempts(w, wait)
+		return
+	}
+
+	userTOTP, err := qtx.GetUserTOTPForUpdate(r.Context(), challenge.UserID)
+	if err != nil {
+		log.Printf("Couldn't get totp secret: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	ok := false
+	switch {
+	case req.Code != "":
+		secret, err := cfg.secretBox.Open(userTOTP.EncryptedSecret)
+		if err != nil {
+			log.Printf("Couldn't decrypt totp secret: %s\n", err)
+			respondWithInternalError(w)
+			return
+		}
+		var step int64
+		step, ok = totp.Validate(secret, req.Code, time.Now(), userTOTP.LastUsedStep)
+		if ok {
+			//the same code can't be used again
+			err = qtx.SetTOTPLastUsedStep(r.Context(), database.SetTOTPLastUsedStepParams{
+				UserID:       challenge.UserID,
+				LastUsedStep: step,
+			})
+			if err != nil {
+				log.Printf("Couldn't save totp step: %s\n", err)
+				respondWithInternalError(w)
+				return
+			}
+		}
+	case req.RecoveryCode != "":
+		codes, err := qtx.GetUnusedRecoveryCodes(r.Context(), challenge.UserID)
+		if err != nil {
+			log.Printf("Couldn't get recovery codes: %s\n", err)
+			respondWithInternalError(w)
+			return
+		}
+		for _, code := range codes {
+			if auth.CheckRecoveryCode(req.RecoveryCode, code.CodeHash) != nil {
+				continue
+			}
+			rows, err := qtx.UseRecoveryCode(r.Context(), code.ID)
+			if err != nil {
+				log.Printf("Couldn't use recovery code: %s\n", err)
+				respondWithInternalError(w)
+				return
+			}
+			ok = rows == 1
+			break
+		}
+	}
+
+	if !ok {
+		log.Printf("Incorrect second factor for user %s\n", challenge.UserID)
+		//the attempt stays counted against the login lockouts
+		err = qtx.FailLoginChallenge(r.Context(), challenge.TokenHash)
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			log.Printf("Couldn't record failed challenge: %s\n", err)
+			respondWithInternalError(w)
+			return
+		}
+		respondWithProblem(w, newProblem(http.StatusUnauthorized, "invalid_code", "Code is incorrect or expired").
+			with("attempts_remaining", loginChallengeMaxAttempts-challenge.Attempts-1).
+			withFieldErrors(FieldError{Field: "code", Code: "invalid", Message: "Code is incorrect or expired"}))
+		return
+	}
+
+	err = qtx.UseLoginChallenge(r.Context(), challenge.TokenHash)
+	if err != nil {
+		log.Printf("Couldn't use login challenge: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("Error committing transaction: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	err = succeedLoginLockouts(r.Context(), lockouts)
+	if err != nil {
+		log.Printf("Couldn't reset login lockout: %s\n", err)
+	}
+	cfg.respondWithSession(w, r, dbUser)
+}
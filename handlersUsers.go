@@ -1,7 +1,10 @@
 package main
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"time"
@@ -9,15 +12,18 @@ import (
 	"github.com/google/uuid"
 	"github.com/skarsden/Chirp/internal/auth"
 	"github.com/skarsden/Chirp/internal/database"
+	"github.com/skarsden/Chirp/internal/mailer"
 )
 
 type User struct {
-	ID          uuid.UUID `json:"id"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	Email       string    `json:"email"`
-	Token       string    `json:"token"`
-	IsChirpyRed bool      `json:"is_chirpy_red"`
+	ID             uuid.UUID `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	Email          string    `json:"email"`
+	Token          string    `json:"token"`
+	IsChirpyRed    bool      `json:"is_chirpy_red"`
+	FollowerCount  int64     `json:"follower_count"`
+	FollowingCount int64     `json:"following_count"`
 }
 
 // Create User
@@ -32,14 +38,21 @@ func (cfg *apiConfig) handlerCreateUser(w http.ResponseWriter, r *http.Request)
 	err := decoder.Decode(&req)
 	if err != nil {
 		log.Printf("Error decoding json request: %s\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondWithError(w, http.StatusBadRequest, "invalid_json", "Request body is not valid JSON")
+		return
+	}
+
+	req.Email = normalizeEmail(req.Email)
+	if !validEmail(req.Email) {
+		log.Printf("Invalid email: %q\n", req.Email)
+		respondWithFieldError(w, http.StatusBadRequest, "invalid_email", "email", "Email must be an address like alice@example.com")
 		return
 	}
 
 	hashed_password, err := auth.HashPassword(req.Password)
 	if err != nil {
 		log.Printf("Error hashing password: %s\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondWithInternalError(w)
 		return
 	}
 
@@ -47,24 +60,35 @@ func (cfg *apiConfig) handlerCreateUser(w http.ResponseWriter, r *http.Request)
 		Email:          req.Email,
 		HashedPassword: hashed_password,
 	})
+	if isUniqueViolation(err) {
+		log.Printf("Email is taken\n")
+		respondWithFieldError(w, http.StatusConflict, "email_taken", "email", "Another account uses that email address")
+		return
+	}
 	if err != nil {
 		log.Printf("Error creating user: %s\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondWithInternalError(w)
 		return
 	}
 
+	//the account can't sign in until the email is confirmed. It's only sent once the user is saved,
+	//and if sending fails the user can ask for it again through the resend endpoint
+	err = cfg.sendEmailVerification(r.Context(), cfg.queries, user.ID, user.Email)
+	if err != nil {
+		log.Printf("Couldn't send verification email: %s\n", err)
+	}
+
 	resp := User{
-		ID:          user.ID,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
-		Email:       user.Email,
-		IsChirpyRed: user.IsChirpyRed,
+		ID:        user.ID,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+		Email:     user.Email,
 	}
 
 	data, err := json.Marshal(resp)
 	if err != nil {
 		log.Printf("Error marshalling JSON: %s\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondWithInternalError(w)
 		return
 	}
 
@@ -80,70 +104,129 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 		Password string `json:"password"`
 	}
 
-	type Response struct {
-		User
-		Token        string `json:"token"`
-		RefreshToken string `json:"refresh_token"`
-	}
-
 	decoder := json.NewDecoder(r.Body)
 	req := reqParams{}
 	err := decoder.Decode(&req)
 	if err != nil {
 		log.Printf("Error decoding json: %s\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondWithError(w, http.StatusBadRequest, "invalid_json", "Request body is not valid JSON")
 		return
 	}
 
-	//get user from database and verify password
-	dbUser, err := cfg.queries.GetUserByEmail(r.Context(), req.Email)
+	//refuse attempts while the account or client is locked out, the rest count as failed until they succeed
+	lockouts := cfg.loginLockouts(r, req.Email)
+	wait, err := attemptLoginLockouts(r.Context(), lockouts)
 	if err != nil {
-		log.Print("Incorrect email or password\n", req.Email)
-		w.WriteHeader(http.StatusUnauthorized)
+		log.Printf("Couldn't check login lockout: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	if wait > 0 {
+		log.Printf("Login locked out for %s\n", wait)
+		respondTooManyAttempts(w, wait)
 		return
 	}
 
-	err = auth.CheckPassword(req.Password, dbUser.HashedPassword)
+	//get user from database and verify password
+	dbUser, err := cfg.queries.GetUserByEmail(r.Context(), normalizeEmail(req.Email))
+	if err == nil {
+		err = auth.CheckPassword(req.Password, dbUser.HashedPassword)
+	}
 	if err != nil {
 		log.Print("Incorrect email or password\n")
-		w.WriteHeader(http.StatusUnauthorized)
+		respondWithError(w, http.StatusUnauthorized, "invalid_credentials", "Incorrect email or password")
 		return
 	}
 
-	//make JWT
-	accessToken, err := auth.MakeJWT(dbUser.ID, cfg.secret, time.Hour)
+	if !dbUser.EmailVerifiedAt.Valid {
+		err = refundLoginLockouts(r.Context(), lockouts)
+		if err != nil {
+			log.Printf("Couldn't refund login attempt: %s\n", err)
+		}
+		log.Printf("User %s hasn't verified their email\n", dbUser.ID)
+		respondWithError(w, http.StatusForbidden, "email_not_verified", "Confirm your email address before signing in")
+		return
+	}
+
+	cfg.finishLogin(w, r, dbUser, lockouts)
+}
+
+// Finish a first factor login, accounts with two factor auth have to complete a challenge before getting tokens.
+// The lockouts the first factor was counted against are only cleared once the login is complete
+func (cfg *apiConfig) finishLogin(w http.ResponseWriter, r *http.Request, dbUser database.User, lockouts []loginLockout) {
+	twoFactor, err := cfg.twoFactorEnabled(r.Context(), dbUser.ID)
 	if err != nil {
-		log.Printf("Couldn't create access token: %s", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		log.Printf("Couldn't check two factor auth: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	if twoFactor {
+		//a right password isn't a failure, but the account's failures stay until the code is right too
+		err = refundLoginLockouts(r.Context(), lockouts)
+		if err != nil {
+			log.Printf("Couldn't refund login attempt: %s\n", err)
+		}
+		cfg.respondWithLoginChallenge(w, r, dbUser.ID)
 		return
 	}
 
-	//make refresh token
-	refreshToken, err := auth.MakeRefreshToken()
+	err = succeedLoginLockouts(r.Context(), lockouts)
 	if err != nil {
-		log.Printf("Couldn't make refresh token: %s\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		log.Printf("Couldn't reset login lockout: %s\n", err)
+	}
+	cfg.respondWithSession(w, r, dbUser)
+}
+
+// Start a new session for a signed in user and respond with its tokens
+func (cfg *apiConfig) respondWithSession(w http.ResponseWriter, r *http.Request, dbUser database.User) {
+	type Response struct {
+		User
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	followCounts, err := cfg.queries.GetFollowCounts(r.Context(), dbUser.ID)
+	if err != nil {
+		log.Printf("Couldn't get follow counts: %s\n", err)
+		respondWithInternalError(w)
 		return
 	}
 
-	_, err = cfg.queries.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
-		UserID:    dbUser.ID,
-		Token:     refreshToken,
-		ExpiresAt: time.Now().UTC().Add(time.Hour * 24 * 60),
-	})
+	isChirpyRed, err := cfg.queries.IsUserChirpyRed(r.Context(), dbUser.ID)
+	if err != nil {
+		log.Printf("Couldn't get subscription: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	//every login is a new session, its refresh tokens share the session's id as their family
+	sessionID := uuid.New()
+
+	//make JWT
+	accessToken, err := auth.MakeSessionJWT(dbUser.ID, sessionID, cfg.jwtKeys, time.Hour)
+	if err != nil {
+		log.Printf("Couldn't create access token: %s", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	//make refresh token
+	refreshToken, err := issueRefreshToken(r, cfg.queries, dbUser.ID, sessionID)
 	if err != nil {
 		log.Printf("Couldn't save refresh token: %s\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondWithInternalError(w)
 		return
 	}
 
 	resp := Response{
 		User: User{
-			ID:          dbUser.ID,
-			Email:       dbUser.Email,
-			CreatedAt:   dbUser.CreatedAt,
-			UpdatedAt:   dbUser.UpdatedAt,
-			IsChirpyRed: dbUser.IsChirpyRed,
+			ID:             dbUser.ID,
+			Email:          dbUser.Email,
+			CreatedAt:      dbUser.CreatedAt,
+			UpdatedAt:      dbUser.UpdatedAt,
+			IsChirpyRed:    isChirpyRed,
+			FollowerCount:  followCounts.FollowerCount,
+			FollowingCount: followCounts.FollowingCount,
 		},
 		Token:        accessToken,
 		RefreshToken: refreshToken,
@@ -152,7 +235,7 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 	data, err := json.Marshal(resp)
 	if err != nil {
 		log.Printf("Error marshalling json: %s\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondWithInternalError(w)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -160,19 +243,52 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 	w.Write(data)
 }
 
-// Update User Password
-func (cfg *apiConfig) handlerUpdateUserPassword(w http.ResponseWriter, r *http.Request) {
-	type reqParams struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+// Stored instead of a hash for accounts that have never had a password, like those created through OIDC.
+// No password matches it
+const unsetPassword = "unset"
+
+// Check the signed in user's current password before a sensitive change. Failures count
+// towards the login lockout so a stolen access token can't be used to guess it
+func (cfg *apiConfig) checkCurrentPassword(w http.ResponseWriter, r *http.Request, user database.User, password string) bool {
+	//a token alone isn't proof for accounts without a password, so they have to set one first
+	if user.HashedPassword == unsetPassword {
+		log.Printf("Sensitive change refused for password-less user %s\n", user.ID)
+		respondWithError(w, http.StatusForbidden, "password_required", "Set a password through 'api/password/forgot' before making this change")
+		return false
 	}
 
-	type Response struct {
-		ID          uuid.UUID `json:"id"`
-		CreatedAt   time.Time `json:"created_at"`
-		UpdatedAt   time.Time `json:"updated_at"`
-		Email       string    `json:"email"`
-		IsChirpyRed bool      `json:"is_chirpy_red"`
+	lockouts := cfg.loginLockouts(r, user.Email)
+	wait, err := attemptLoginLockouts(r.Context(), lockouts)
+	if err != nil {
+		log.Printf("Couldn't check login lockout: %s\n", err)
+		respondWithInternalError(w)
+		return false
+	}
+	if wait > 0 {
+		log.Printf("Password check locked out for %s\n", wait)
+		respondTooManyAttempts(w, wait)
+		return false
+	}
+
+	err = auth.CheckPassword(password, user.HashedPassword)
+	if err != nil {
+		log.Printf("Incorrect current password for user %s\n", user.ID)
+		respondWithFieldError(w, http.StatusUnauthorized, "invalid_credentials", "current_password", "Current password is incorrect")
+		return false
+	}
+
+	err = succeedLoginLockouts(r.Context(), lockouts)
+	if err != nil {
+		log.Printf("Couldn't reset login lockout: %s\n", err)
+	}
+	return true
+}
+
+// Change Password
+func (cfg *apiConfig) handlerChangePassword(w http.ResponseWriter, r *http.Request) {
+	type reqParams struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
 	}
 
 	decoder := json.NewDecoder(r.Body)
@@ -180,51 +296,166 @@ func (cfg *apiConfig) handlerUpdateUserPassword(w http.ResponseWriter, r *http.R
 	err := decoder.Decode(&req)
 	if err != nil {
 		log.Printf("Error decoding json: %s\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondWithError(w, http.StatusBadRequest, "invalid_json", "Request body is not valid JSON")
 		return
 	}
 
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		log.Printf("Couldn't find access token: %s\n", err)
-		w.WriteHeader(http.StatusBadRequest)
+	if req.NewPassword == "" {
+		log.Printf("Empty new password\n")
+		respondWithFieldError(w, http.StatusBadRequest, "invalid_password", "new_password", "Password can't be empty")
 		return
 	}
 
-	userID, err := auth.ValidateJWT(token, cfg.secret)
+	userID := requestUserID(r)
+	user, err := cfg.queries.GetUserByID(r.Context(), userID)
 	if err != nil {
-		log.Printf("Couldn't validate access token: %s\n", err)
-		w.WriteHeader(http.StatusUnauthorized)
+		log.Printf("Couldn't get user: %s\n", err)
+		respondWithInternalError(w)
 		return
 	}
 
-	hashedPass, err := auth.HashPassword(req.Password)
+	if !cfg.checkCurrentPassword(w, r, user, req.CurrentPassword) {
+		return
+	}
+
+	hashedPass, err := auth.HashPassword(req.NewPassword)
 	if err != nil {
 		log.Printf("Couldn't hash new password: %s\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondWithInternalError(w)
 		return
 	}
 
-	user, err := cfg.queries.UpdateUserPassword(r.Context(), database.UpdateUserPasswordParams{
-		Email:          req.Email,
-		HashedPassword: hashedPass,
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.queries.WithTx(tx)
+
+	err = qtx.SetUserHashedPassword(r.Context(), database.SetUserHashedPasswordParams{
 		ID:             userID,
+		HashedPassword: hashedPass,
 	})
+	if err != nil {
+		log.Printf("Couldn't update password: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
 
-	resp := Response{
-		ID:          user.ID,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   time.Now().UTC(),
-		Email:       user.Email,
-		IsChirpyRed: user.IsChirpyRed,
+	//outstanding reset links would undo the change
+	err = qtx.UseUserPasswordResets(r.Context(), userID)
+	if err != nil {
+		log.Printf("Couldn't use reset tokens: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	//sign out everywhere else, keeping the session that made the change
+	authUser, _ := authUserFromContext(r.Context())
+	session := authUser.Claims.Session()
+	if session.Valid {
+		_, err = qtx.RevokeOtherSessions(r.Context(), database.RevokeOtherSessionsParams{
+			UserID:   userID,
+			FamilyID: session.UUID,
+		})
+	} else {
+		_, err = qtx.RevokeAllSessions(r.Context(), userID)
 	}
-	data, err := json.Marshal(resp)
 	if err != nil {
-		log.Printf("Error marshalling json: %s\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		log.Printf("Couldn't revoke sessions: %s\n", err)
+		respondWithInternalError(w)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	w.Write(data)
+
+	//personal tokens all go, including one making this request, they'd outlive the old password otherwise
+	_, err = qtx.RevokeAllPersonalTokens(r.Context(), userID)
+	if err != nil {
+		log.Printf("Couldn't revoke personal tokens: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("Error committing transaction: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Change Email, the new address only takes over once it's confirmed
+func (cfg *apiConfig) handlerChangeEmail(w http.ResponseWriter, r *http.Request) {
+	type reqParams struct {
+		Email           string `json:"email"`
+		CurrentPassword string `json:"current_password"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	req := reqParams{}
+	err := decoder.Decode(&req)
+	if err != nil {
+		log.Printf("Error decoding json: %s\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid_json", "Request body is not valid JSON")
+		return
+	}
+
+	req.Email = normalizeEmail(req.Email)
+	if !validEmail(req.Email) {
+		log.Printf("Invalid email: %q\n", req.Email)
+		respondWithFieldError(w, http.StatusBadRequest, "invalid_email", "email", "Email must be an address like alice@example.com")
+		return
+	}
+
+	userID := requestUserID(r)
+	user, err := cfg.queries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		log.Printf("Couldn't get user: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	if !cfg.checkCurrentPassword(w, r, user, req.CurrentPassword) {
+		return
+	}
+
+	if req.Email == user.Email {
+		log.Printf("Email is unchanged\n")
+		respondWithFieldError(w, http.StatusBadRequest, "email_unchanged", "email", "That is already your email address")
+		return
+	}
+
+	_, err = cfg.queries.GetUserByEmail(r.Context(), req.Email)
+	if err == nil {
+		log.Printf("Email is taken\n")
+		respondWithFieldError(w, http.StatusConflict, "email_taken", "email", "Another account uses that email address")
+		return
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Couldn't check email: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	err = cfg.sendEmailVerification(r.Context(), cfg.queries, userID, req.Email)
+	if err != nil {
+		log.Printf("Couldn't send verification email: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	//let the current address know, in case the change wasn't theirs
+	err = cfg.mailer.Send(r.Context(), mailer.Message{
+		To:      user.Email,
+		Subject: "Your Chirpy email is being changed",
+		Body:    fmt.Sprintf("Someone signed in to your account asked to change its email to %s. The change happens once the new address is confirmed. If this wasn't you, reset your password.\n", req.Email),
+	})
+	if err != nil {
+		log.Printf("Couldn't send email change notice: %s\n", err)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
 }
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/skarsden/Chirp/internal/auth"
+	"github.com/skarsden/Chirp/internal/database"
+	"github.com/skarsden/Chirp/internal/lockout"
+	"github.com/skarsden/Chirp/internal/mailer"
+)
+
+// How long the links sent by email work for
+const (
+	emailVerificationLifetime = 24 * time.Hour
+	passwordResetLifetime     = time.Hour
+)
+
+// Emails anyone can ask to be sent to an address, verification or reset links, before backing off.
+// Each kind is counted separately, and a new link doesn't expire the old ones
+var emailAccountPolicy = lockout.Policy{
+	FreeAttempts: 3,
+	BaseDelay:    time.Minute,
+	MaxDelay:     time.Hour,
+	Window:       24 * time.Hour,
+}
+
+// Emails that can be asked for per client IP, higher since many users can share one
+var emailIPPolicy = lockout.Policy{
+	FreeAttempts: 20,
+	BaseDelay:    time.Minute,
+	MaxDelay:     time.Hour,
+	Window:       24 * time.Hour,
+}
+
+// Limits on sending kind of email to an address on request, counted whether or not the account exists
+func (cfg *apiConfig) emailLockouts(r *http.Request, kind, email string) []loginLockout {
+	return []loginLockout{
+		{limiter: cfg.emailLimiter, key: kind + ":" + normalizeEmail(email)},
+		{limiter: cfg.emailIPLimiter, key: kind + "-ip:" + clientIP(r)},
+	}
+}
+
+// Check that email is a bare address like alice@example.com
+func validEmail(email string) bool {
+	addr, err := mail.ParseAddress(email)
+	return err == nil && addr.Address == email
+}
+
+// Emails are stored and looked up lower cased, so Alice@Example.com and alice@example.com are one account
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// Check whether err is postgres refusing a duplicate, e.g. a second account with the same email
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// Link to a page of the app, carrying token
+func (cfg *apiConfig) appLink(page, token string) string {
+	return cfg.appURL + "/" + page + "?token=" + url.QueryEscape(token)
+}
+
+// Save a verification token for email and send it the link
+func (cfg *apiConfig) sendEmailVerification(ctx context.Context, q *database.Queries, userID uuid.UUID, email string) error {
+	token, err := auth.MakeOneTimeToken()
+	if err != nil {
+		return err
+	}
+	err = q.CreateEmailVerification(ctx, database.CreateEmailVerificationParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    userID,
+		Email:     email,
+		ExpiresAt: time.Now().UTC().Add(emailVerificationLifetime),
+	})
+	if err != nil {
+		return err
+	}
+	return cfg.mailer.Send(ctx, mailer.Message{
+		To:      email,
+		Subject: "Confirm your email for Chirpy",
+		Body: fmt.Sprintf("Open this link to confirm your email address:\n\n%s\n\nThe link works once and expires in %s. If you didn't ask for this you can ignore this email.\n",
+			cfg.appLink("verify", token), emailVerificationLifetime),
+	})
+}
+
+// Verify Email
+func (cfg *apiConfig) handlerVerifyEmail(w http.ResponseWriter, r *http.Request) {
+	type reqParams struct {
+		Token string `json:"token"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	req := reqParams{}
+	err := decoder.Decode(&req)
+	if err != nil {
+		log.Printf("Error decoding json: %s\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid_json", "Request body is not valid JSON")
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.queries.WithTx(tx)
+
+	verification, err := qtx.GetEmailVerificationForUpdate(r.Context(), auth.HashToken(req.Token))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Couldn't get verification token: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	if err != nil || verification.UsedAt.Valid || !verification.ExpiresAt.After(time.Now().UTC()) {
+		log.Printf("Invalid, used or expired verification token\n")
+		respondWithFieldError(w, http.StatusBadRequest, "invalid_token", "token", "Verification link is invalid, expired or already used")
+		return
+	}
+
+	err = qtx.UseEmailVerification(r.Context(), verification.TokenHash)
+	if err != nil {
+		log.Printf("Couldn't use verification token: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	_, err = qtx.VerifyUserEmail(r.Context(), database.VerifyUserEmailParams{
+		ID:    verification.UserID,
+		Email: verification.Email,
+	})
+	if isUniqueViolation(err) {
+		log.Printf("Email was taken before it was confirmed: %s\n", err)
+		respondWithError(w, http.StatusConflict, "email_taken", "Another account uses that email address")
+		return
+	}
+	if err != nil {
+		log.Printf("Couldn't verify email: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("Error committing transaction: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Resend Verification, always accepted so it can't be used to find out which emails have accounts.
+// Every request counts towards the limit whether or not the account exists, those over it are dropped
+func (cfg *apiConfig) handlerResendVerification(w http.ResponseWriter, r *http.Request) {
+	type reqParams struct {
+		Email string `json:"email"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	req := reqParams{}
+	err := decoder.Decode(&req)
+	if err != nil {
+		log.Printf("Error decoding json: %s\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid_json", "Request body is not valid JSON")
+		return
+	}
+
+	wait, err := attemptLoginLockouts(r.Context(), cfg.emailLockouts(r, "resend", req.Email))
+	if err != nil {
+		log.Printf("Couldn't check resend limit: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	if wait > 0 {
+		log.Printf("Verification resend limited for %s\n", wait)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	user, err := cfg.queries.GetUserByEmail(r.Context(), normalizeEmail(req.Email))
+	if err != nil {
+		log.Printf("No user to resend verification to: %s\n", err)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if user.EmailVerifiedAt.Valid {
+		log.Printf("User %s has already verified their email\n", user.ID)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	err = cfg.sendEmailVerification(r.Context(), cfg.queries, user.ID, user.Email)
+	if err != nil {
+		//still accepted, an error here would give away that the account exists
+		log.Printf("Couldn't resend verification email: %s\n", err)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Forgot Password, always accepted so it can't be used to find out which emails have accounts
+func (cfg *apiConfig) handlerForgotPassword(w http.ResponseWriter, r *http.Request) {
+	type reqParams struct {
+		Email string `json:"email"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	req := reqParams{}
+	err := decoder.Decode(&req)
+	if err != nil {
+		log.Printf("Error decoding json: %s\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid_json", "Request body is not valid JSON")
+		return
+	}
+
+	wait, err := attemptLoginLockouts(r.Context(), cfg.emailLockouts(r, "reset", req.Email))
+	if err != nil {
+		log.Printf("Couldn't check reset limit: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	if wait > 0 {
+		log.Printf("Password reset email limited for %s\n", wait)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	user, err := cfg.queries.GetUserByEmail(r.Context(), normalizeEmail(req.Email))
+	if err != nil {
+		log.Printf("No user to reset password for: %s\n", err)
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	token, err := auth.MakeOneTimeToken()
+	if err != nil {
+		log.Printf("Couldn't make reset token: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	err = cfg.queries.CreatePasswordReset(r.Context(), database.CreatePasswordResetParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().UTC().Add(passwordResetLifetime),
+	})
+	if err != nil {
+		log.Printf("Couldn't save reset token: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	err = cfg.mailer.Send(r.Context(), mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your Chirpy password",
+		Body: fmt.Sprintf("Open this link to choose a new password:\n\n%s\n\nThe link works once and expires in %s. If you didn't ask to reset your password you can ignore this email.\n",
+			cfg.appLink("reset-password", token), passwordResetLifetime),
+	})
+	if err != nil {
+		//still accepted, an error here would give away that the account exists
+		log.Printf("Couldn't send reset email: %s\n", err)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// Reset Password
+func (cfg *apiConfig) handlerResetPassword(w http.ResponseWriter, r *http.Request) {
+	type reqParams struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	req := reqParams{}
+	err := decoder.Decode(&req)
+	if err != nil {
+		log.Printf("Error decoding json: %s\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid_json", "Request body is not valid JSON")
+		return
+	}
+
+	if req.Password == "" {
+		log.Printf("Empty new password\n")
+		respondWithFieldError(w, http.StatusBadRequest, "invalid_password", "password", "Password can't be empty")
+		return
+	}
+
+	hashedPass, err := auth.HashPassword(req.Password)
+	if err != nil {
+		log.Printf("Couldn't hash new password: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		log.Printf("Error starting transaction: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.queries.WithTx(tx)
+
+	reset, err := qtx.GetPasswordResetForUpdate(r.Context(), auth.HashToken(req.Token))
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Couldn't get reset token: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	if err != nil || reset.UsedAt.Valid || !reset.ExpiresAt.After(time.Now().UTC()) {
+		log.Printf("Invalid, used or expired reset token\n")
+		respondWithFieldError(w, http.StatusBadRequest, "invalid_token", "token", "Reset link is invalid, expired or already used")
+		return
+	}
+
+	//every outstanding reset link stops working, not just this one
+	err = qtx.UseUserPasswordResets(r.Context(), reset.UserID)
+	if err != nil {
+		log.Printf("Couldn't use reset tokens: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	err = qtx.SetUserHashedPassword(r.Context(), database.SetUserHashedPasswordParams{
+		ID:             reset.UserID,
+		HashedPassword: hashedPass,
+	})
+	if err != nil {
+		log.Printf("Couldn't update password: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	//whoever knew the old password shouldn't stay signed in, or keep tokens they made with it
+	_, err = qtx.RevokeAllSessions(r.Context(), reset.UserID)
+	if err != nil {
+		log.Printf("Couldn't revoke sessions: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	_, err = qtx.RevokeAllPersonalTokens(r.Context(), reset.UserID)
+	if err != nil {
+		log.Printf("Couldn't revoke personal tokens: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("Error committing transaction: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
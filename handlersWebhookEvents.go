@@ -0,0 +1,169 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skarsden/Chirp/internal/database"
+)
+
+// A webhook delivery from the ledger
+type WebhookEvent struct {
+	ID          uuid.UUID       `json:"id"`
+	Provider    string          `json:"provider"`
+	EventID     string          `json:"event_id"`
+	EventType   string          `json:"event_type"`
+	Payload     json.RawMessage `json:"payload"`
+	ReceivedAt  time.Time       `json:"received_at"`
+	OccurredAt  *time.Time      `json:"occurred_at"`
+	Status      string          `json:"status"`
+	Attempts    int32           `json:"attempts"`
+	LastError   string          `json:"last_error,omitempty"`
+	ProcessedAt *time.Time      `json:"processed_at"`
+}
+
+// Page of webhook events, newest first
+type WebhookEventsPage struct {
+	Events     []WebhookEvent `json:"events"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+func webhookEventFromDB(row database.WebhookEvent) WebhookEvent {
+	event := WebhookEvent{
+		ID:         row.ID,
+		Provider:   row.Provider,
+		EventID:    row.EventID,
+		EventType:  row.EventType,
+		Payload:    row.Payload,
+		ReceivedAt: row.ReceivedAt,
+		Status:     row.Status,
+		Attempts:   row.Attempts,
+		LastError:  row.LastError.String,
+	}
+	if row.OccurredAt.Valid {
+		event.OccurredAt = &row.OccurredAt.Time
+	}
+	if row.ProcessedAt.Valid {
+		event.ProcessedAt = &row.ProcessedAt.Time
+	}
+	return event
+}
+
+// Get Webhook Events, optionally only those with a status
+func (cfg *apiConfig) handlerGetWebhookEvents(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	status := sql.NullString{}
+	if s := query.Get("status"); s != "" {
+		if !slices.Contains([]string{webhookPending, webhookProcessed, webhookIgnored, webhookStale, webhookFailed}, s) {
+			log.Printf("Invalid webhook status: %q\n", s)
+			respondWithFieldError(w, http.StatusBadRequest, "invalid_status", "status", "status must be pending, processed, ignored, stale or failed")
+			return
+		}
+		status = sql.NullString{String: s, Valid: true}
+	}
+
+	limit, err := parsePageLimit(query.Get("limit"))
+	if err != nil {
+		log.Printf("Invalid limit: %s\n", err)
+		respondWithFieldError(w, http.StatusBadRequest, "invalid_limit", "limit", "limit must be a positive integer")
+		return
+	}
+
+	cursorReceivedAt, cursorID, err := parseCursorParam(query.Get("cursor"))
+	if err != nil {
+		log.Printf("Invalid cursor: %s\n", err)
+		respondWithFieldError(w, http.StatusBadRequest, "invalid_cursor", "cursor", "cursor must be a next_cursor value from a previous response")
+		return
+	}
+
+	//fetch one extra row to find out if there is a next page
+	dbEvents, err := cfg.queries.ListWebhookEvents(r.Context(), database.ListWebhookEventsParams{
+		Status:           status,
+		CursorReceivedAt: cursorReceivedAt,
+		CursorID:         cursorID,
+		Limit:            int32(limit + 1),
+	})
+	if err != nil {
+		log.Printf("Couldn't get webhook events: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	page := WebhookEventsPage{Events: []WebhookEvent{}}
+	if len(dbEvents) > limit {
+		dbEvents = dbEvents[:limit]
+		last := dbEvents[len(dbEvents)-1]
+		page.NextCursor = encodeCursor(last.ReceivedAt, last.ID)
+	}
+	for _, dbEvent := range dbEvents {
+		page.Events = append(page.Events, webhookEventFromDB(dbEvent))
+	}
+
+	data, err := json.Marshal(page)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// Replay Webhook Event, applies a failed event again
+func (cfg *apiConfig) handlerReplayWebhookEvent(w http.ResponseWriter, r *http.Request) {
+	eventID, err := uuid.Parse(r.PathValue("eventID"))
+	if err != nil {
+		log.Printf("Invalid webhook event ID: %s\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid_event_id", "Event ID must be a valid uuid")
+		return
+	}
+
+	event, err := cfg.queries.GetWebhookEvent(r.Context(), eventID)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Printf("No webhook event %s\n", eventID)
+		respondWithError(w, http.StatusNotFound, "event_not_found", "Webhook event not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Couldn't get webhook event: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	if event.Status != webhookFailed {
+		log.Printf("Webhook event %s is %s, not failed\n", eventID, event.Status)
+		respondWithProblem(w, newProblem(http.StatusConflict, "event_not_failed", "Only failed events can be replayed").
+			with("status", event.Status))
+		return
+	}
+
+	//a failure is recorded on the event, so the response shows it either way
+	err = cfg.processWebhookEvent(r.Context(), eventID)
+	if err != nil {
+		log.Printf("Replay of webhook event %s failed: %s\n", eventID, err)
+	}
+
+	event, err = cfg.queries.GetWebhookEvent(r.Context(), eventID)
+	if err != nil {
+		log.Printf("Couldn't get webhook event: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	data, err := json.Marshal(webhookEventFromDB(event))
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skarsden/Chirp/internal/auth"
+	"github.com/skarsden/Chirp/internal/database"
+	"github.com/skarsden/Chirp/internal/webhooks"
+)
+
+// Shortest signing secret an integrator can pick, leave it out to have one generated
+const webhookSecretMinLength = 16
+
+// An integrator's subscription to Chirpy events, the secret is only shown once when it's created
+type WebhookSubscription struct {
+	ID        uuid.UUID `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"created_at"`
+	Secret    string    `json:"secret,omitempty"`
+}
+
+// One event sent, or still being sent, to a subscription
+type WebhookDelivery struct {
+	ID             uuid.UUID       `json:"id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int32           `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at"`
+	LastStatusCode *int32          `json:"last_status_code"`
+	LastError      string          `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+}
+
+// Page of webhook deliveries, newest first
+type WebhookDeliveriesPage struct {
+	Deliveries []WebhookDelivery `json:"deliveries"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+func webhookSubscriptionFromDB(row database.WebhookSubscription) WebhookSubscription {
+	return WebhookSubscription{
+		ID:        row.ID,
+		URL:       row.Url,
+		Events:    strings.Fields(row.Events),
+		CreatedAt: row.CreatedAt,
+	}
+}
+
+func webhookDeliveryFromDB(row database.WebhookDelivery) WebhookDelivery {
+	delivery := WebhookDelivery{
+		ID:        row.ID,
+		EventType: row.EventType,
+		Payload:   row.Payload,
+		Status:    row.Status,
+		Attempts:  row.Attempts,
+		LastError: row.LastError.String,
+		CreatedAt: row.CreatedAt,
+	}
+	//only pending deliveries have another attempt coming
+	if row.Status == webhooks.StatusPending {
+		delivery.NextAttemptAt = &row.NextAttemptAt
+	}
+	if row.LastStatusCode.Valid {
+		delivery.LastStatusCode = &row.LastStatusCode.Int32
+	}
+	if row.DeliveredAt.Valid {
+		delivery.DeliveredAt = &row.DeliveredAt.Time
+	}
+	return delivery
+}
+
+// Queue an event for every subscription that wants it, in the same transaction as the change it's about
+func enqueueWebhookEvent(ctx context.Context, q *database.Queries, event string, data any) error {
+	payload, err := webhooks.NewPayload(event, data)
+	if err != nil {
+		return err
+	}
+	_, err = q.EnqueueWebhookDeliveries(ctx, database.EnqueueWebhookDeliveriesParams{
+		EventType: event,
+		Payload:   payload,
+	})
+	return err
+}
+
+// Create Webhook Subscription
+func (cfg *apiConfig) handlerCreateWebhookSubscription(w http.ResponseWriter, r *http.Request) {
+	type reqParams struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+		Secret string   `json:"secret"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	req := reqParams{}
+	err := decoder.Decode(&req)
+	if err != nil {
+		log.Printf("Error decoding json: %s\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid_json", "Request body is not valid JSON")
+		return
+	}
+
+	fieldErrors := []FieldError{}
+	//plain http is only for trying things out locally, the payloads aren't encrypted
+	target, err := url.Parse(req.URL)
+	if err != nil || target.Host == "" || (target.Scheme != "https" && (target.Scheme != "http" || cfg.platform != "dev")) {
+		fieldErrors = append(fieldErrors, FieldError{Field: "url", Code: "invalid", Message: "URL must be an absolute https URL"})
+	}
+	events := slices.Compact(slices.Sorted(slices.Values(req.Events)))
+	if len(events) == 0 {
+		fieldErrors = append(fieldErrors, FieldError{Field: "events", Code: "required", Message: "Subscription needs at least one event"})
+	} else if slices.ContainsFunc(events, func(event string) bool { return !webhooks.ValidEvent(event) }) {
+		fieldErrors = append(fieldErrors, FieldError{Field: "events", Code: "invalid", Message: "Events must be " + webhooks.AllEvents + " or from " + strings.Join(webhooks.Events, ", ")})
+	}
+	if req.Secret != "" && len(req.Secret) < webhookSecretMinLength {
+		fieldErrors = append(fieldErrors, FieldError{Field: "secret", Code: "too_short", Message: "Secret must be at least 16 characters, leave it out to have one generated"})
+	}
+	//integrators mustn't be able to reach our own network through their subscriptions
+	if len(fieldErrors) == 0 && cfg.platform != "dev" {
+		err = webhooks.CheckTarget(r.Context(), target.String())
+		if errors.Is(err, webhooks.ErrPrivateTarget) {
+			fieldErrors = append(fieldErrors, FieldError{Field: "url", Code: "private", Message: "URL must not point at a loopback, private or link-local address"})
+		} else if err != nil {
+			log.Printf("Couldn't resolve webhook url: %s\n", err)
+			fieldErrors = append(fieldErrors, FieldError{Field: "url", Code: "unresolvable", Message: "URL's host couldn't be resolved"})
+		}
+	}
+	if len(fieldErrors) > 0 {
+		log.Printf("Invalid webhook subscription request: %v\n", fieldErrors)
+		respondWithProblem(w, newProblem(http.StatusBadRequest, "invalid_webhook_subscription", "Webhook subscription request is invalid").
+			withFieldErrors(fieldErrors...))
+		return
+	}
+
+	//a wildcard already covers everything, so it's stored on its own
+	if slices.Contains(events, webhooks.AllEvents) {
+		events = []string{webhooks.AllEvents}
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		secret, err = auth.MakeOneTimeToken()
+		if err != nil {
+			log.Printf("Couldn't make webhook secret: %s\n", err)
+			respondWithInternalError(w)
+			return
+		}
+	}
+	encryptedSecret, err := cfg.secretBox.Seal(secret)
+	if err != nil {
+		log.Printf("Couldn't encrypt webhook secret: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	dbSubscription, err := cfg.queries.CreateWebhookSubscription(r.Context(), database.CreateWebhookSubscriptionParams{
+		Url:             target.String(),
+		Events:          strings.Join(events, " "),
+		EncryptedSecret: encryptedSecret,
+	})
+	if err != nil {
+		log.Printf("Couldn't save webhook subscription: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	resp := webhookSubscriptionFromDB(dbSubscription)
+	resp.Secret = secret
+
+	data, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	w.Write(data)
+}
+
+// Get Webhook Subscriptions
+func (cfg *apiConfig) handlerGetWebhookSubscriptions(w http.ResponseWriter, r *http.Request) {
+	dbSubscriptions, err := cfg.queries.ListWebhookSubscriptions(r.Context())
+	if err != nil {
+		log.Printf("Couldn't get webhook subscriptions: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	subscriptions := make([]WebhookSubscription, 0, len(dbSubscriptions))
+	for _, dbSubscription := range dbSubscriptions {
+		subscriptions = append(subscriptions, webhookSubscriptionFromDB(dbSubscription))
+	}
+
+	data, err := json.Marshal(subscriptions)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// Delete Webhook Subscription, its queued deliveries and log go with it
+func (cfg *apiConfig) handlerDeleteWebhookSubscription(w http.ResponseWriter, r *http.Request) {
+	subscriptionID, err := uuid.Parse(r.PathValue("subscriptionID"))
+	if err != nil {
+		log.Printf("Invalid webhook subscription ID: %s\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid_subscription_id", "Subscription ID must be a valid uuid")
+		return
+	}
+
+	rows, err := cfg.queries.DeleteWebhookSubscription(r.Context(), subscriptionID)
+	if err != nil {
+		log.Printf("Couldn't delete webhook subscription: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	if rows == 0 {
+		log.Printf("No webhook subscription %s\n", subscriptionID)
+		respondWithError(w, http.StatusNotFound, "subscription_not_found", "Webhook subscription not found")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Get Webhook Deliveries for a subscription, optionally only those with a status
+func (cfg *apiConfig) handlerGetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	subscriptionID, err := uuid.Parse(r.PathValue("subscriptionID"))
+	if err != nil {
+		log.Printf("Invalid webhook subscription ID: %s\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid_subscription_id", "Subscription ID must be a valid uuid")
+		return
+	}
+
+	query := r.URL.Query()
+
+	status := sql.NullString{}
+	if s := query.Get("status"); s != "" {
+		if !slices.Contains([]string{webhooks.StatusPending, webhooks.StatusSucceeded, webhooks.StatusDead}, s) {
+			log.Printf("Invalid webhook delivery status: %q\n", s)
+			respondWithFieldError(w, http.StatusBadRequest, "invalid_status", "status", "status must be pending, succeeded or dead")
+			return
+		}
+		status = sql.NullString{String: s, Valid: true}
+	}
+
+	limit, err := parsePageLimit(query.Get("limit"))
+	if err != nil {
+		log.Printf("Invalid limit: %s\n", err)
+		respondWithFieldError(w, http.StatusBadRequest, "invalid_limit", "limit", "limit must be a positive integer")
+		return
+	}
+
+	cursorCreatedAt, cursorID, err := parseCursorParam(query.Get("cursor"))
+	if err != nil {
+		log.Printf("Invalid cursor: %s\n", err)
+		respondWithFieldError(w, http.StatusBadRequest, "invalid_cursor", "cursor", "cursor must be a next_cursor value from a previous response")
+		return
+	}
+
+	_, err = cfg.queries.GetWebhookSubscription(r.Context(), subscriptionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Printf("No webhook subscription %s\n", subscriptionID)
+		respondWithError(w, http.StatusNotFound, "subscription_not_found", "Webhook subscription not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Couldn't get webhook subscription: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	//fetch one extra row to find out if there is a next page
+	dbDeliveries, err := cfg.queries.ListWebhookDeliveries(r.Context(), database.ListWebhookDeliveriesParams{
+		SubscriptionID:  subscriptionID,
+		Status:          status,
+		CursorCreatedAt: cursorCreatedAt,
+		CursorID:        cursorID,
+		Limit:           int32(limit + 1),
+	})
+	if err != nil {
+		log.Printf("Couldn't get webhook deliveries: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	page := WebhookDeliveriesPage{Deliveries: []WebhookDelivery{}}
+	if len(dbDeliveries) > limit {
+		dbDeliveries = dbDeliveries[:limit]
+		last := dbDeliveries[len(dbDeliveries)-1]
+		page.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+	for _, dbDelivery := range dbDeliveries {
+		page.Deliveries = append(page.Deliveries, webhookDeliveryFromDB(dbDelivery))
+	}
+
+	data, err := json.Marshal(page)
+	if err != nil {
+		log.Printf("Error marshalling JSON: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
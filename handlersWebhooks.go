@@ -1,55 +1,290 @@
 package main
 
 import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"io"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/skarsden/Chirp/internal/auth"
+	"github.com/skarsden/Chirp/internal/database"
+	"github.com/skarsden/Chirp/internal/webhooks"
 )
 
-func (cfg *apiConfig) handlerUpdateUserChirpyRed(w http.ResponseWriter, r *http.Request) {
+const (
+	// provider recorded on Polka's events in the webhook ledger
+	polkaProvider = "polka"
+	// largest webhook body we'll read
+	webhookMaxBytes = 1 << 20
+)
+
+// Plan recorded when Polka doesn't say which one, it only sells Chirpy Red
+const defaultSubscriptionPlan = "chirpy_red"
+
+// Subscription statuses, only active subscriptions inside their period make a user Chirpy Red
+const (
+	subscriptionActive   = "active"
+	subscriptionCanceled = "canceled"
+	subscriptionExpired  = "expired"
+)
+
+// Webhook event statuses. Pending and failed events can still be applied, the rest are done with
+const (
+	webhookPending   = "pending"
+	webhookProcessed = "processed"
+	webhookIgnored   = "ignored"
+	webhookStale     = "stale"
+	webhookFailed    = "failed"
+)
+
+// Event for a user we don't have, Polka is told so it can stop retrying
+var errWebhookUserNotFound = errors.New("user not found")
+
+// Polka Webhook, every delivery is recorded in the ledger and applied at most once
+func (cfg *apiConfig) handlerPolkaWebhook(w http.ResponseWriter, r *http.Request) {
 	type reqParams struct {
-		Event string `json:"event"`
-		Data  struct {
-			UserID uuid.UUID `json:"user_id"`
-		} `json:"data"`
+		ID        string          `json:"id"`
+		Event     string          `json:"event"`
+		CreatedAt *time.Time      `json:"created_at"`
+		Data      json.RawMessage `json:"data"`
 	}
 
-	apikey, err := auth.GetApiKey(r.Header)
+	//the signature covers the raw body, so read it before decoding
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, webhookMaxBytes))
 	if err != nil {
-		log.Printf("Couldn't get api key: %s\n", err)
-		w.WriteHeader(http.StatusUnauthorized)
+		log.Printf("Couldn't read webhook body: %s\n", err)
+		respondWithError(w, http.StatusBadRequest, "invalid_body", "Couldn't read request body")
 		return
 	}
 
-	if apikey != cfg.polka_key {
-		log.Printf("Invalid api key")
-		w.WriteHeader(http.StatusUnauthorized)
+	if !cfg.checkPolkaWebhook(w, r, body) {
 		return
 	}
 
 	req := reqParams{}
-	decoder := json.NewDecoder(r.Body)
-	err = decoder.Decode(&req)
+	err = json.Unmarshal(body, &req)
 	if err != nil {
 		log.Printf("Couldn't decode json: %s\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
+		respondWithError(w, http.StatusBadRequest, "invalid_json", "Request body is not valid JSON")
 		return
 	}
 
-	if req.Event != "user.upgraded" {
-		w.WriteHeader(http.StatusNoContent)
-		return
+	eventID := webhooks.InboundEventID(req.ID, req.Event, req.Data)
+	occurredAt := sql.NullTime{}
+	if req.CreatedAt != nil {
+		occurredAt = sql.NullTime{Time: req.CreatedAt.UTC(), Valid: true}
 	}
 
-	_, err = cfg.queries.UpdateUserChirpyRed(r.Context(), req.Data.UserID)
+	event, err := cfg.queries.CreateWebhookEvent(r.Context(), database.CreateWebhookEventParams{
+		Provider:   polkaProvider,
+		EventID:    eventID,
+		EventType:  req.Event,
+		Payload:    body,
+		OccurredAt: occurredAt,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		log.Printf("Duplicate webhook event %s\n", eventID)
+		event, err = cfg.queries.GetWebhookEventByEventID(r.Context(), database.GetWebhookEventByEventIDParams{
+			Provider: polkaProvider,
+			EventID:  eventID,
+		})
+	}
 	if err != nil {
+		log.Printf("Couldn't record webhook event: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+
+	err = cfg.processWebhookEvent(r.Context(), event.ID)
+	if errors.Is(err, errWebhookUserNotFound) {
 		log.Printf("User not found: %s", err)
-		w.WriteHeader(http.StatusNotFound)
+		respondWithError(w, http.StatusNotFound, "user_not_found", "User not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Couldn't process webhook event %s: %s\n", event.ID, err)
+		respondWithInternalError(w)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// Check that a webhook came from Polka, by its signature or the legacy ApiKey header
+func (cfg *apiConfig) checkPolkaWebhook(w http.ResponseWriter, r *http.Request, body []byte) bool {
+	if cfg.polkaVerifier != nil {
+		err := cfg.polkaVerifier.Verify(r.Header, body, time.Now())
+		if err != nil {
+			log.Printf("Invalid webhook signature: %s\n", err)
+			respondWithError(w, http.StatusUnauthorized, "invalid_signature", "Webhook signature is missing, invalid or expired")
+			return false
+		}
+		return true
+	}
+
+	//only on the dev platform, when neither a secret nor a key is set
+	if cfg.polka_key == "" {
+		log.Printf("Polka webhook received while they're turned off\n")
+		respondWithError(w, http.StatusForbidden, "polka_disabled", "Polka webhooks are turned off")
+		return false
+	}
+
+	apikey, err := auth.GetApiKey(r.Header)
+	if err != nil {
+		log.Printf("Couldn't get api key: %s\n", err)
+		respondWithError(w, http.StatusUnauthorized, "missing_api_key", "Request is missing an ApiKey authorization header")
+		return false
+	}
+
+	if subtle.ConstantTimeCompare([]byte(apikey), []byte(cfg.polka_key)) != 1 {
+		log.Printf("Invalid api key")
+		respondWithError(w, http.StatusUnauthorized, "invalid_api_key", "API key is invalid")
+		return false
+	}
+	return true
+}
+
+// Apply a recorded webhook event unless it's already been handled, and record the outcome on it
+func (cfg *apiConfig) processWebhookEvent(ctx context.Context, eventID uuid.UUID) error {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.queries.WithTx(tx)
+
+	//the lock makes concurrent deliveries of the same event wait for each other
+	event, err := qtx.GetWebhookEventForUpdate(ctx, eventID)
+	if err != nil {
+		return err
+	}
+	if event.Status != webhookPending && event.Status != webhookFailed {
+		return nil
+	}
+
+	status, applyErr := applyPolkaEvent(ctx, qtx, event)
+	if applyErr != nil {
+		//nothing the event did is kept, only that it failed
+		tx.Rollback()
+		err = cfg.queries.FinishWebhookEvent(ctx, database.FinishWebhookEventParams{
+			ID:        event.ID,
+			Status:    webhookFailed,
+			LastError: sql.NullString{String: applyErr.Error(), Valid: true},
+		})
+		if err != nil {
+			log.Printf("Couldn't record failed webhook event: %s\n", err)
+		}
+		return applyErr
+	}
+
+	err = qtx.FinishWebhookEvent(ctx, database.FinishWebhookEventParams{
+		ID:     event.ID,
+		Status: status,
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Update the user's subscription for a Polka event, returning the event's new status
+func applyPolkaEvent(ctx context.Context, q *database.Queries, event database.WebhookEvent) (string, error) {
+	type payload struct {
+		Data struct {
+			UserID           uuid.UUID  `json:"user_id"`
+			Plan             string     `json:"plan"`
+			CurrentPeriodEnd *time.Time `json:"current_period_end"`
+		} `json:"data"`
+	}
+
+	//new status for the subscription, other events aren't for us
+	var status string
+	switch event.EventType {
+	case "user.upgraded", "subscription.renewed":
+		status = subscriptionActive
+	case "user.downgraded":
+		status = subscriptionCanceled
+	case "subscription.expired":
+		status = subscriptionExpired
+	default:
+		return webhookIgnored, nil
+	}
+
+	p := payload{}
+	err := json.Unmarshal(event.Payload, &p)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = q.GetUserByID(ctx, p.Data.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", errWebhookUserNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	//events without a timestamp are ordered by when they arrived
+	eventAt := event.ReceivedAt
+	if event.OccurredAt.Valid {
+		eventAt = event.OccurredAt.Time
+	}
+	subscription, err := q.GetSubscriptionForUpdate(ctx, p.Data.UserID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+	if err == nil && subscription.LastEventAt.Valid && eventAt.Before(subscription.LastEventAt.Time) {
+		log.Printf("Webhook event %s is older than the last one applied to user %s\n", event.EventID, p.Data.UserID)
+		return webhookStale, nil
+	}
+	wasActive := err == nil && subscription.Status == subscriptionActive
+	lastEventAt := sql.NullTime{Time: eventAt, Valid: true}
+
+	if status == subscriptionActive {
+		plan := p.Data.Plan
+		if plan == "" {
+			plan = defaultSubscriptionPlan
+		}
+		periodEnd := sql.NullTime{}
+		if p.Data.CurrentPeriodEnd != nil {
+			periodEnd = sql.NullTime{Time: p.Data.CurrentPeriodEnd.UTC(), Valid: true}
+		}
+		_, err = q.ActivateSubscription(ctx, database.ActivateSubscriptionParams{
+			UserID:           p.Data.UserID,
+			Plan:             plan,
+			CurrentPeriodEnd: periodEnd,
+			LastEventAt:      lastEventAt,
+		})
+		//renewals aren't news to integrators, only the upgrade itself
+		if err == nil && !wasActive {
+			err = enqueueWebhookEvent(ctx, q, webhooks.EventUserUpgraded, map[string]any{
+				"user_id":            p.Data.UserID,
+				"plan":               plan,
+				"current_period_end": p.Data.CurrentPeriodEnd,
+			})
+		}
+	} else {
+		//ending a subscription the user never had leaves nothing to record
+		_, err = q.SetSubscriptionStatus(ctx, database.SetSubscriptionStatusParams{
+			UserID:      p.Data.UserID,
+			Status:      status,
+			LastEventAt: lastEventAt,
+		})
+		if err == nil && wasActive {
+			err = enqueueWebhookEvent(ctx, q, webhooks.EventUserDowngraded, map[string]any{
+				"user_id": p.Data.UserID,
+				"status":  status,
+			})
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	return webhookProcessed, nil
+}
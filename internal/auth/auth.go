@@ -2,10 +2,12 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
@@ -28,48 +30,111 @@ func CheckPassword(password, hash string) error {
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 }
 
-// MakeJWT
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
-		Issuer:    "chirpy",
-		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
-		Subject:   userID.String(),
-	})
-	return token.SignedString([]byte(tokenSecret))
+// Claims carried by access tokens
+type Claims struct {
+	jwt.RegisteredClaims
+	Scope string `json:"scope,omitempty"`
+	// login session the token was issued for, empty for tokens not tied to one
+	SessionID string `json:"sid,omitempty"`
+}
+
+// Session the token was issued for, if any
+func (c *Claims) Session() uuid.NullUUID {
+	id, err := uuid.Parse(c.SessionID)
+	if err != nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: id, Valid: true}
+}
+
+// Scopes granted to the token
+func (c *Claims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+// Check that the token was granted every one of scopes
+func (c *Claims) HasScopes(scopes ...string) bool {
+	granted := c.Scopes()
+	for _, scope := range scopes {
+		if !slices.Contains(granted, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// MakeJWT with every scope, for users signing in with their password
+func MakeJWT(userID uuid.UUID, keys *KeySet, expiresIn time.Duration) (string, error) {
+	return MakeScopedJWT(userID, keys, expiresIn, AllScopes)
+}
+
+// MakeJWT with every scope for a login session, so the session can be told apart from the user's others
+func MakeSessionJWT(userID, sessionID uuid.UUID, keys *KeySet, expiresIn time.Duration) (string, error) {
+	return signJWT(keys, newClaims(userID, expiresIn, AllScopes, sessionID.String()))
+}
+
+// MakeJWT limited to scopes, signed with the key set's active key
+func MakeScopedJWT(userID uuid.UUID, keys *KeySet, expiresIn time.Duration, scopes []string) (string, error) {
+	return signJWT(keys, newClaims(userID, expiresIn, scopes, ""))
+}
+
+func newClaims(userID uuid.UUID, expiresIn time.Duration, scopes []string, sessionID string) Claims {
+	return Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    "chirpy",
+			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
+			Subject:   userID.String(),
+		},
+		Scope:     strings.Join(scopes, " "),
+		SessionID: sessionID,
+	}
+}
+
+func signJWT(keys *KeySet, claims Claims) (string, error) {
+	token := jwt.NewWithClaims(keys.active.Method, claims)
+	token.Header["kid"] = keys.active.ID
+	return token.SignedString(keys.active.Signer)
 }
 
 // ValidateJWT
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	claimsStruct := jwt.RegisteredClaims{}
+func ValidateJWT(tokenString string, keys *KeySet) (uuid.UUID, error) {
+	_, userID, err := ParseJWT(tokenString, keys)
+	return userID, err
+}
+
+// Validate JWT and return its claims along with the user ID
+func ParseJWT(tokenString string, keys *KeySet) (*Claims, uuid.UUID, error) {
+	claimsStruct := Claims{}
 	token, err := jwt.ParseWithClaims(
 		tokenString,
 		&claimsStruct,
-		func(token *jwt.Token) (interface{}, error) { return []byte(tokenSecret), nil },
+		keys.verificationKey,
+		jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}),
 	)
 	if err != nil {
-		return uuid.Nil, err
+		return nil, uuid.Nil, err
 	}
 
 	userIdString, err := token.Claims.GetSubject()
 	if err != nil {
-		return uuid.Nil, err
+		return nil, uuid.Nil, err
 	}
 
 	issuer, err := token.Claims.GetIssuer()
 	if err != nil {
-		return uuid.Nil, err
+		return nil, uuid.Nil, err
 	}
 
 	if issuer != "chirpy" {
-		return uuid.Nil, errors.New("invalid issuer")
+		return nil, uuid.Nil, errors.New("invalid issuer")
 	}
 
 	id, err := uuid.Parse(userIdString)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("invalid user ID: %w\n", err)
+		return nil, uuid.Nil, fmt.Errorf("invalid user ID: %w\n", err)
 	}
-	return id, nil
+	return &claimsStruct, id, nil
 }
 
 // Get Bearer Token
@@ -100,6 +165,15 @@ func GetApiKey(headers http.Header) (string, error) {
 
 // Make Refresh Token
 func MakeRefreshToken() (string, error) {
+	return makeToken()
+}
+
+// Make a single use token, e.g. for links sent by email or login challenges
+func MakeOneTimeToken() (string, error) {
+	return makeToken()
+}
+
+func makeToken() (string, error) {
 	data := make([]byte, 32)
 	_, err := rand.Read(data)
 	if err != nil {
@@ -108,3 +182,9 @@ func MakeRefreshToken() (string, error) {
 	hexToken := hex.EncodeToString(data)
 	return hexToken, nil
 }
+
+// Hash Token, only the hash of refresh and email tokens is stored so a database leak doesn't leak them
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,7 +1,12 @@
 package auth
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -63,35 +68,57 @@ func TestCheckPassword(t *testing.T) {
 	}
 }
 
+// Key set with a fresh Ed25519 key
+func newTestKeySet(t *testing.T, id string) *KeySet {
+	t.Helper()
+	key, err := GenerateSigningKey(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := NewKeySet(key, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
 func TestVaildateJWT(t *testing.T) {
 	userID := uuid.New()
-	validToken, _ := MakeJWT(userID, "secret", time.Hour)
+	keys := newTestKeySet(t, "key-1")
+	validToken, _ := MakeJWT(userID, keys, time.Hour)
 
 	tests := []struct {
 		name        string
 		tokenString string
-		tokenSecret string
+		keys        *KeySet
 		wantUserID  uuid.UUID
 		wantErr     bool
 	}{
 		{
 			name:        "valid token",
 			tokenString: validToken,
-			tokenSecret: "secret",
+			keys:        keys,
 			wantUserID:  userID,
 			wantErr:     false,
 		},
 		{
 			name:        "invalid token",
 			tokenString: "invalid_token_string",
-			tokenSecret: "secret",
+			keys:        keys,
+			wantUserID:  uuid.Nil,
+			wantErr:     true,
+		},
+		{
+			name:        "wrong key",
+			tokenString: validToken,
+			keys:        newTestKeySet(t, "key-1"),
 			wantUserID:  uuid.Nil,
 			wantErr:     true,
 		},
 		{
-			name:        "wrong secret",
+			name:        "unknown kid",
 			tokenString: validToken,
-			tokenSecret: "wrong_secret",
+			keys:        newTestKeySet(t, "key-2"),
 			wantUserID:  uuid.Nil,
 			wantErr:     true,
 		},
@@ -99,7 +126,7 @@ func TestVaildateJWT(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotUserID, err := ValidateJWT(tt.tokenString, tt.tokenSecret)
+			gotUserID, err := ValidateJWT(tt.tokenString, tt.keys)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("validateJWT() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -111,6 +138,111 @@ func TestVaildateJWT(t *testing.T) {
 	}
 }
 
+func TestParseJWTScopes(t *testing.T) {
+	userID := uuid.New()
+	keys := newTestKeySet(t, "key-1")
+	fullToken, _ := MakeJWT(userID, keys, time.Hour)
+	readToken, _ := MakeScopedJWT(userID, keys, time.Hour, []string{ScopeChirpsRead})
+	emptyToken, _ := MakeScopedJWT(userID, keys, time.Hour, nil)
+	sessionToken, _ := MakeSessionJWT(userID, uuid.New(), keys, time.Hour)
+
+	tests := []struct {
+		name        string
+		tokenString string
+		scopes      []string
+		want        bool
+	}{
+		{
+			name:        "full token has write scope",
+			tokenString: fullToken,
+			scopes:      []string{ScopeChirpsWrite, ScopeUsersWrite},
+			want:        true,
+		},
+		{
+			name:        "scoped token has its scope",
+			tokenString: readToken,
+			scopes:      []string{ScopeChirpsRead},
+			want:        true,
+		},
+		{
+			name:        "scoped token lacks other scope",
+			tokenString: readToken,
+			scopes:      []string{ScopeChirpsRead, ScopeChirpsWrite},
+			want:        false,
+		},
+		{
+			name:        "token without scopes",
+			tokenString: emptyToken,
+			scopes:      []string{ScopeChirpsRead},
+			want:        false,
+		},
+		{
+			name:        "session token has every scope",
+			tokenString: sessionToken,
+			scopes:      AllScopes,
+			want:        true,
+		},
+		{
+			name:        "no scopes required",
+			tokenString: emptyToken,
+			scopes:      nil,
+			want:        true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, gotUserID, err := ParseJWT(tt.tokenString, keys)
+			if err != nil {
+				t.Fatalf("ParseJWT() error = %v", err)
+			}
+			if gotUserID != userID {
+				t.Errorf("ParseJWT() gotUserID = %v, want %v", gotUserID, userID)
+			}
+			if got := claims.HasScopes(tt.scopes...); got != tt.want {
+				t.Errorf("HasScopes(%v) = %v, want %v", tt.scopes, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionClaim(t *testing.T) {
+	userID := uuid.New()
+	sessionID := uuid.New()
+	keys := newTestKeySet(t, "key-1")
+	sessionToken, _ := MakeSessionJWT(userID, sessionID, keys, time.Hour)
+	scopedToken, _ := MakeScopedJWT(userID, keys, time.Hour, AllScopes)
+
+	tests := []struct {
+		name        string
+		tokenString string
+		want        uuid.NullUUID
+	}{
+		{
+			name:        "session token",
+			tokenString: sessionToken,
+			want:        uuid.NullUUID{UUID: sessionID, Valid: true},
+		},
+		{
+			name:        "token without session",
+			tokenString: scopedToken,
+			want:        uuid.NullUUID{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, _, err := ParseJWT(tt.tokenString, keys)
+			if err != nil {
+				t.Fatalf("ParseJWT() error = %v", err)
+			}
+			if got := claims.Session(); got != tt.want {
+				t.Errorf("Session() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestGetBearerToken(t *testing.T) {
 	header := http.Header{}
 	header.Set("Authorization", "Bearer Test")
@@ -153,3 +285,356 @@ func TestGetBearerToken(t *testing.T) {
 		})
 	}
 }
+
+func TestHashToken(t *testing.T) {
+	token, _ := MakeRefreshToken()
+	other, _ := MakeRefreshToken()
+
+	tests := []struct {
+		name      string
+		a         string
+		b         string
+		wantEqual bool
+	}{
+		{
+			name:      "same token hashes the same",
+			a:         token,
+			b:         token,
+			wantEqual: true,
+		},
+		{
+			name:      "different tokens hash differently",
+			a:         token,
+			b:         other,
+			wantEqual: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hashA := HashToken(tt.a)
+			if hashA == tt.a || len(hashA) != 64 {
+				t.Errorf("HashToken() = %v, want a hex sha-256 digest", hashA)
+			}
+			if got := hashA == HashToken(tt.b); got != tt.wantEqual {
+				t.Errorf("hashes equal = %v, want %v", got, tt.wantEqual)
+			}
+		})
+	}
+}
+
+func TestPersonalToken(t *testing.T) {
+	token, err := MakePersonalToken()
+	if err != nil {
+		t.Fatalf("MakePersonalToken() error = %v", err)
+	}
+	refresh, _ := MakeRefreshToken()
+
+	tests := []struct {
+		name  string
+		token string
+		want  bool
+	}{
+		{
+			name:  "personal token",
+			token: token,
+			want:  true,
+		},
+		{
+			name:  "refresh token",
+			token: refresh,
+			want:  false,
+		},
+		{
+			name:  "jwt",
+			token: "eyJhbGciOiJFZERTQSJ9.e30.sig",
+			want:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsPersonalToken(tt.token); got != tt.want {
+				t.Errorf("IsPersonalToken() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Personal tokens by hash, like the personal_tokens table
+type fakeTokenStore map[string]PersonalTokenRecord
+
+func (s fakeTokenStore) PersonalTokenByHash(ctx context.Context, tokenHash string) (PersonalTokenRecord, error) {
+	token, ok := s[tokenHash]
+	if !ok {
+		return PersonalTokenRecord{}, sql.ErrNoRows
+	}
+	return token, nil
+}
+
+// Save record as a new personal token and return its value
+func (s fakeTokenStore) add(t *testing.T, record PersonalTokenRecord) string {
+	t.Helper()
+	token, err := MakePersonalToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	record.ID = uuid.New()
+	record.Scope = ScopeChirpsRead
+	s[HashToken(token)] = record
+	return token
+}
+
+func TestAuthenticate(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	keys := newTestKeySet(t, "key-1")
+	userID := uuid.New()
+	tokens := fakeTokenStore{}
+
+	jwtToken, _ := MakeJWT(userID, keys, time.Hour)
+	personal := tokens.add(t, PersonalTokenRecord{UserID: userID})
+	expiring := tokens.add(t, PersonalTokenRecord{UserID: userID, ExpiresAt: sql.NullTime{Time: now.Add(time.Hour), Valid: true}})
+	expired := tokens.add(t, PersonalTokenRecord{UserID: userID, ExpiresAt: sql.NullTime{Time: now.Add(-time.Minute), Valid: true}})
+	revoked := tokens.add(t, PersonalTokenRecord{UserID: userID, RevokedAt: sql.NullTime{Time: now.Add(-time.Minute), Valid: true}})
+	unknown, _ := MakePersonalToken()
+
+	tests := []struct {
+		name         string
+		token        string
+		wantUserID   uuid.UUID
+		wantPersonal bool
+		wantErr      bool
+	}{
+		{name: "jwt", token: jwtToken, wantUserID: userID},
+		{name: "bad jwt", token: "invalid_token_string", wantErr: true},
+		{name: "personal token", token: personal, wantUserID: userID, wantPersonal: true},
+		{name: "unexpired personal token", token: expiring, wantUserID: userID, wantPersonal: true},
+		{name: "expired personal token", token: expired, wantErr: true},
+		{name: "revoked personal token", token: revoked, wantErr: true},
+		{name: "unknown personal token", token: unknown, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Authenticate(ctx, tt.token, keys, tokens, now)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Errorf("Authenticate() error = %v, want ErrInvalidToken", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate() error = %v", err)
+			}
+			if got.UserID != tt.wantUserID || got.PersonalTokenID.Valid != tt.wantPersonal {
+				t.Errorf("Authenticate() = %v personal %v, want %v personal %v", got.UserID, got.PersonalTokenID.Valid, tt.wantUserID, tt.wantPersonal)
+			}
+		})
+	}
+}
+
+func TestValidateScopes(t *testing.T) {
+	tests := []struct {
+		name    string
+		scopes  []string
+		wantErr bool
+	}{
+		{
+			name:   "known scopes",
+			scopes: []string{ScopeChirpsRead, ScopeChirpsWrite},
+		},
+		{
+			name:    "unknown scope",
+			scopes:  []string{ScopeChirpsWrite, "admin"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateScopes(tt.scopes)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateScopes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := MakeRecoveryCodes()
+	if err != nil {
+		t.Fatalf("MakeRecoveryCodes() error = %v", err)
+	}
+	if len(codes) != RecoveryCodeCount {
+		t.Fatalf("MakeRecoveryCodes() made %d codes, want %d", len(codes), RecoveryCodeCount)
+	}
+	hash, _ := HashRecoveryCode(codes[0])
+
+	tests := []struct {
+		name    string
+		code    string
+		wantErr bool
+	}{
+		{
+			name:    "same code",
+			code:    codes[0],
+			wantErr: false,
+		},
+		{
+			name:    "typed without dash in capitals",
+			code:    strings.ToUpper(strings.ReplaceAll(codes[0], "-", "")),
+			wantErr: false,
+		},
+		{
+			name:    "other code",
+			code:    codes[1],
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckRecoveryCode(tt.code, hash)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckRecoveryCode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSecretBox(t *testing.T) {
+	key := make([]byte, 32)
+	box, _ := NewSecretBox(key)
+	otherKey := make([]byte, 32)
+	otherKey[0] = 1
+	otherBox, _ := NewSecretBox(otherKey)
+
+	sealed, err := box.Seal("JBSWY3DPEHPK3PXP")
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		box     *SecretBox
+		sealed  string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "round trip",
+			box:    box,
+			sealed: sealed,
+			want:   "JBSWY3DPEHPK3PXP",
+		},
+		{
+			name:    "wrong key",
+			box:     otherBox,
+			sealed:  sealed,
+			wantErr: true,
+		},
+		{
+			name:    "tampered",
+			box:     box,
+			sealed:  sealed[:len(sealed)-4] + "AAAA",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.box.Open(tt.sealed)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Open() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Open() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookVerifier(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"event":"user.upgraded"}`)
+	verifier, err := NewWebhookVerifier([]string{"new-secret", "old-secret"}, 5*time.Minute)
+	if err != nil {
+		t.Fatalf("NewWebhookVerifier() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		timestamp int64
+		signature string
+		body      []byte
+		wantErr   bool
+	}{
+		{
+			name:      "valid",
+			timestamp: now.Unix(),
+			signature: SignWebhook([]byte("new-secret"), now.Unix(), body),
+			body:      body,
+		},
+		{
+			name:      "old secret during rotation",
+			timestamp: now.Unix(),
+			signature: SignWebhook([]byte("old-secret"), now.Unix(), body),
+			body:      body,
+		},
+		{
+			name:      "one of several signatures",
+			timestamp: now.Unix(),
+			signature: SignWebhook([]byte("unknown"), now.Unix(), body) + ", " + SignWebhook([]byte("new-secret"), now.Unix(), body),
+			body:      body,
+		},
+		{
+			name:      "wrong secret",
+			timestamp: now.Unix(),
+			signature: SignWebhook([]byte("guess"), now.Unix(), body),
+			body:      body,
+			wantErr:   true,
+		},
+		{
+			name:      "tampered body",
+			timestamp: now.Unix(),
+			signature: SignWebhook([]byte("new-secret"), now.Unix(), body),
+			body:      []byte(`{"event":"user.downgraded"}`),
+			wantErr:   true,
+		},
+		{
+			name:      "replayed after tolerance",
+			timestamp: now.Add(-10 * time.Minute).Unix(),
+			signature: SignWebhook([]byte("new-secret"), now.Add(-10*time.Minute).Unix(), body),
+			body:      body,
+			wantErr:   true,
+		},
+		{
+			name:      "timestamp in the future",
+			timestamp: now.Add(10 * time.Minute).Unix(),
+			signature: SignWebhook([]byte("new-secret"), now.Add(10*time.Minute).Unix(), body),
+			body:      body,
+			wantErr:   true,
+		},
+		{
+			name:      "timestamp not signed",
+			timestamp: now.Unix() - 1,
+			signature: SignWebhook([]byte("new-secret"), now.Unix(), body),
+			body:      body,
+			wantErr:   true,
+		},
+		{
+			name:      "missing signature",
+			timestamp: now.Unix(),
+			body:      body,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := http.Header{}
+			headers.Set(WebhookTimestampHeader, strconv.FormatInt(tt.timestamp, 10))
+			if tt.signature != "" {
+				headers.Set(WebhookSignatureHeader, tt.signature)
+			}
+			err := verifier.Verify(headers, tt.body, now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
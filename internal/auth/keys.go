@@ -0,0 +1,213 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"maps"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Key used to sign access tokens, identified in the token header by its kid
+type SigningKey struct {
+	ID     string
+	Method jwt.SigningMethod
+	Signer crypto.Signer
+	// zero while the key is in use, tokens it signed stay valid for the key set's grace window after
+	RetiredAt time.Time
+}
+
+// Create a signing key from a private key, RSA keys sign with RS256 and Ed25519 keys with EdDSA
+func NewSigningKey(id string, private crypto.PrivateKey) (*SigningKey, error) {
+	switch key := private.(type) {
+	case *rsa.PrivateKey:
+		return &SigningKey{ID: id, Method: jwt.SigningMethodRS256, Signer: key}, nil
+	case ed25519.PrivateKey:
+		return &SigningKey{ID: id, Method: jwt.SigningMethodEdDSA, Signer: key}, nil
+	default:
+		return nil, fmt.Errorf("key %s: unsupported key type %T", id, private)
+	}
+}
+
+// Generate a new Ed25519 signing key
+func GenerateSigningKey(id string) (*SigningKey, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return NewSigningKey(id, private)
+}
+
+// KeySet signs new tokens with its active key and verifies tokens signed by any
+// of its keys, including retired ones until their grace window ends
+type KeySet struct {
+	active *SigningKey
+	keys   map[string]*SigningKey
+	grace  time.Duration
+}
+
+// Create key set, active signs new tokens and retired keys are only used to verify
+func NewKeySet(active *SigningKey, grace time.Duration, retired ...*SigningKey) (*KeySet, error) {
+	ks := &KeySet{
+		active: active,
+		keys:   map[string]*SigningKey{active.ID: active},
+		grace:  grace,
+	}
+	for _, key := range retired {
+		if _, ok := ks.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key id %s", key.ID)
+		}
+		if key.RetiredAt.IsZero() {
+			return nil, fmt.Errorf("key %s: retired keys need a retired_at time", key.ID)
+		}
+		ks.keys[key.ID] = key
+	}
+	return ks, nil
+}
+
+// Key set file, key paths are relative to the file
+type keySetFile struct {
+	Active string `json:"active"`
+	Keys   []struct {
+		ID        string    `json:"kid"`
+		Path      string    `json:"path"`
+		RetiredAt time.Time `json:"retired_at"`
+	} `json:"keys"`
+}
+
+// Load a key set from a json file listing PEM encoded PKCS #8 private keys
+func LoadKeySet(path string, grace time.Duration) (*KeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	file := keySetFile{}
+	err = json.Unmarshal(data, &file)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	var active *SigningKey
+	retired := []*SigningKey{}
+	for _, entry := range file.Keys {
+		keyPath := entry.Path
+		if !filepath.IsAbs(keyPath) {
+			keyPath = filepath.Join(filepath.Dir(path), keyPath)
+		}
+		key, err := loadSigningKey(entry.ID, keyPath)
+		if err != nil {
+			return nil, err
+		}
+		if entry.ID == file.Active {
+			active = key
+			continue
+		}
+		key.RetiredAt = entry.RetiredAt
+		retired = append(retired, key)
+	}
+	if active == nil {
+		return nil, fmt.Errorf("%s: active key %q is not in keys", path, file.Active)
+	}
+	return NewKeySet(active, grace, retired...)
+}
+
+func loadSigningKey(id, path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("key %s: %s is not a PEM encoded PKCS #8 private key", id, path)
+	}
+	private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("key %s: %w", id, err)
+	}
+	return NewSigningKey(id, private)
+}
+
+// Look up the key a token was signed with, retired keys are refused once their grace window ends
+func (ks *KeySet) verificationKey(token *jwt.Token) (interface{}, error) {
+	id, ok := token.Header["kid"].(string)
+	if !ok {
+		return nil, errors.New("token has no kid header")
+	}
+	key, ok := ks.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %s", id)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("key %s signs with %s, not %s", id, key.Method.Alg(), token.Method.Alg())
+	}
+	if !ks.usable(key, time.Now()) {
+		return nil, fmt.Errorf("key %s was retired", id)
+	}
+	return key.Signer.Public(), nil
+}
+
+func (ks *KeySet) usable(key *SigningKey, now time.Time) bool {
+	return key.RetiredAt.IsZero() || now.Before(key.RetiredAt.Add(ks.grace))
+}
+
+// JSON Web Key holding a public key
+type JWK struct {
+	KeyType   string `json:"kty"`
+	ID        string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	// Ed25519
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+}
+
+// JSON Web Key Set as served from /.well-known/jwks.json
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// Public keys other services can verify tokens with, retired keys are listed until their grace window ends
+func (ks *KeySet) JWKS() JWKS {
+	now := time.Now()
+	jwks := JWKS{Keys: []JWK{publicJWK(ks.active)}}
+	ids := slices.Sorted(maps.Keys(ks.keys))
+	for _, id := range ids {
+		key := ks.keys[id]
+		if key == ks.active || !ks.usable(key, now) {
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, publicJWK(key))
+	}
+	return jwks
+}
+
+func publicJWK(key *SigningKey) JWK {
+	jwk := JWK{ID: key.ID, Use: "sig", Algorithm: key.Method.Alg()}
+	switch public := key.Signer.Public().(type) {
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	}
+	return jwk
+}
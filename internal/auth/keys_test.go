@@ -0,0 +1,155 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestKeyRotation(t *testing.T) {
+	userID := uuid.New()
+	oldKey, _ := GenerateSigningKey("old")
+	newKey, _ := GenerateSigningKey("new")
+	before, _ := NewKeySet(oldKey, time.Hour)
+	oldToken, _ := MakeJWT(userID, before, time.Hour)
+
+	tests := []struct {
+		name      string
+		retiredAt time.Time
+		wantErr   bool
+		wantKeys  int
+	}{
+		{
+			name:      "retired key inside grace window",
+			retiredAt: time.Now().Add(-30 * time.Minute),
+			wantErr:   false,
+			wantKeys:  2,
+		},
+		{
+			name:      "retired key past grace window",
+			retiredAt: time.Now().Add(-2 * time.Hour),
+			wantErr:   true,
+			wantKeys:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			retired := *oldKey
+			retired.RetiredAt = tt.retiredAt
+			after, err := NewKeySet(newKey, time.Hour, &retired)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = ValidateJWT(oldToken, after)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateJWT() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := len(after.JWKS().Keys); got != tt.wantKeys {
+				t.Errorf("JWKS() has %d keys, want %d", got, tt.wantKeys)
+			}
+
+			newToken, _ := MakeJWT(userID, after, time.Hour)
+			if _, err := ValidateJWT(newToken, after); err != nil {
+				t.Errorf("ValidateJWT() new token error = %v", err)
+			}
+		})
+	}
+}
+
+// Write a PKCS #8 PEM file into dir
+func writeTestKey(t *testing.T, dir, name string, private any) {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	err = os.WriteFile(filepath.Join(dir, name), data, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadKeySet(t *testing.T) {
+	dir := t.TempDir()
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	writeTestKey(t, dir, "rsa.pem", rsaKey)
+	writeTestKey(t, dir, "ed.pem", edKey)
+
+	tests := []struct {
+		name      string
+		keySet    string
+		wantAlg   string
+		wantErr   bool
+		wantTypes []string
+	}{
+		{
+			name:      "ed25519 active with retired rsa",
+			keySet:    `{"active": "ed", "keys": [{"kid": "ed", "path": "ed.pem"}, {"kid": "rsa", "path": "rsa.pem", "retired_at": "` + time.Now().Format(time.RFC3339) + `"}]}`,
+			wantAlg:   "EdDSA",
+			wantTypes: []string{"OKP", "RSA"},
+		},
+		{
+			name:      "rsa active",
+			keySet:    `{"active": "rsa", "keys": [{"kid": "rsa", "path": "rsa.pem"}]}`,
+			wantAlg:   "RS256",
+			wantTypes: []string{"RSA"},
+		},
+		{
+			name:    "missing active key",
+			keySet:  `{"active": "other", "keys": [{"kid": "rsa", "path": "rsa.pem"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "retired key without retired_at",
+			keySet:  `{"active": "ed", "keys": [{"kid": "ed", "path": "ed.pem"}, {"kid": "rsa", "path": "rsa.pem"}]}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "keys.json")
+			os.WriteFile(path, []byte(tt.keySet), 0600)
+
+			keys, err := LoadKeySet(path, time.Hour)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadKeySet() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			jwks := keys.JWKS()
+			if jwks.Keys[0].Algorithm != tt.wantAlg {
+				t.Errorf("active key alg = %s, want %s", jwks.Keys[0].Algorithm, tt.wantAlg)
+			}
+			if len(jwks.Keys) != len(tt.wantTypes) {
+				t.Fatalf("JWKS() has %d keys, want %d", len(jwks.Keys), len(tt.wantTypes))
+			}
+			for i, jwk := range jwks.Keys {
+				if jwk.KeyType != tt.wantTypes[i] {
+					t.Errorf("JWKS() key %d type = %s, want %s", i, jwk.KeyType, tt.wantTypes[i])
+				}
+			}
+
+			userID := uuid.New()
+			token, _ := MakeJWT(userID, keys, time.Hour)
+			got, err := ValidateJWT(token, keys)
+			if err != nil || got != userID {
+				t.Errorf("ValidateJWT() = %v, %v, want %v", got, err, userID)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Personal tokens start with this so they can be told apart from JWTs, and spotted by secret scanners
+const PersonalTokenPrefix = "chirpy_pat_"
+
+// Make a personal API token, long lived and only stored as a hash like refresh tokens
+func MakePersonalToken() (string, error) {
+	token, err := makeToken()
+	if err != nil {
+		return "", err
+	}
+	return PersonalTokenPrefix + token, nil
+}
+
+// Check whether a bearer token is a personal token rather than a JWT
+func IsPersonalToken(token string) bool {
+	return strings.HasPrefix(token, PersonalTokenPrefix)
+}
+
+// A bearer token that didn't check out, as opposed to a failure looking it up
+var ErrInvalidToken = errors.New("invalid token")
+
+// A stored personal token, as much of it as Authenticate needs
+type PersonalTokenRecord struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Scope     string
+	ExpiresAt sql.NullTime
+	RevokedAt sql.NullTime
+}
+
+// PersonalTokenStore finds personal tokens by their hash, returning sql.ErrNoRows for unknown ones
+type PersonalTokenStore interface {
+	PersonalTokenByHash(ctx context.Context, tokenHash string) (PersonalTokenRecord, error)
+}
+
+// Who a bearer token belongs to and what it may do
+type Identity struct {
+	UserID uuid.UUID
+	Claims *Claims
+	// set when the token is a personal token rather than a JWT
+	PersonalTokenID uuid.NullUUID
+}
+
+// Authenticate a bearer token, either a JWT signed with keys or a personal token in tokens that
+// isn't revoked or expired at now. Tokens that don't check out wrap ErrInvalidToken
+func Authenticate(ctx context.Context, token string, keys *KeySet, tokens PersonalTokenStore, now time.Time) (Identity, error) {
+	if !IsPersonalToken(token) {
+		claims, userID, err := ParseJWT(token, keys)
+		if err != nil {
+			return Identity{}, fmt.Errorf("%w: %s", ErrInvalidToken, err)
+		}
+		return Identity{UserID: userID, Claims: claims}, nil
+	}
+
+	personal, err := tokens.PersonalTokenByHash(ctx, HashToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Identity{}, fmt.Errorf("%w: unknown personal token", ErrInvalidToken)
+	}
+	if err != nil {
+		return Identity{}, err
+	}
+	if personal.RevokedAt.Valid || (personal.ExpiresAt.Valid && !personal.ExpiresAt.Time.After(now.UTC())) {
+		return Identity{}, fmt.Errorf("%w: personal token %s is revoked or expired", ErrInvalidToken, personal.ID)
+	}
+
+	return Identity{
+		UserID:          personal.UserID,
+		Claims:          PersonalTokenClaims(personal.UserID, personal.Scope),
+		PersonalTokenID: uuid.NullUUID{UUID: personal.ID, Valid: true},
+	}, nil
+}
+
+// Claims for a request made with a personal token, so handlers see the same thing as for a JWT
+func PersonalTokenClaims(userID uuid.UUID, scope string) *Claims {
+	return &Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:  "chirpy",
+			Subject: userID.String(),
+		},
+		Scope: scope,
+	}
+}
+
+// Check that every one of scopes is known
+func ValidateScopes(scopes []string) error {
+	for _, scope := range scopes {
+		if !slices.Contains(AllScopes, scope) {
+			return fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+	return nil
+}
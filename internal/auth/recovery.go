@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Number of recovery codes issued when two factor auth is set up
+const RecoveryCodeCount = 10
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Make recovery codes like "abcd-efgh", each works once in place of a TOTP code
+func MakeRecoveryCodes() ([]string, error) {
+	codes := make([]string, 0, RecoveryCodeCount)
+	for range RecoveryCodeCount {
+		data := make([]byte, 5)
+		_, err := rand.Read(data)
+		if err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(recoveryEncoding.EncodeToString(data))
+		codes = append(codes, code[:4]+"-"+code[4:])
+	}
+	return codes, nil
+}
+
+// Strip the formatting users might type differently
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, code)
+}
+
+// Hash recovery code with bcrypt
+func HashRecoveryCode(code string) (string, error) {
+	data, err := bcrypt.GenerateFromPassword([]byte(normalizeRecoveryCode(code)), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Check recovery code against its hash
+func CheckRecoveryCode(code, hash string) error {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(normalizeRecoveryCode(code)))
+}
@@ -0,0 +1,24 @@
+package auth
+
+// Scopes an access token can be limited to
+const (
+	// read chirps private to the user, e.g. their timeline and mentions
+	ScopeChirpsRead = "chirps:read"
+	// post, edit, delete, like and rechirp chirps
+	ScopeChirpsWrite = "chirps:write"
+	// upload media
+	ScopeMediaWrite = "media:write"
+	// see the user's account details and sessions
+	ScopeUsersRead = "users:read"
+	// change the user's account, sessions and who they follow
+	ScopeUsersWrite = "users:write"
+)
+
+// Every scope, granted to tokens from a password login
+var AllScopes = []string{
+	ScopeChirpsRead,
+	ScopeChirpsWrite,
+	ScopeMediaWrite,
+	ScopeUsersRead,
+	ScopeUsersWrite,
+}
@@ -0,0 +1,60 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+
+	err = store.Put(ctx, "blob1", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	file, err := store.Open(ctx, "blob1")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	data, _ := io.ReadAll(file)
+	file.Close()
+	if string(data) != "hello" {
+		t.Errorf("Open() read %q, want %q", data, "hello")
+	}
+
+	err = store.Delete(ctx, "blob1")
+	if err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	_, err = store.Open(ctx, "blob1")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Open() after delete error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestFileStoreInvalidKeys(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+
+	tests := []string{"", ".", "..", "../escape", "a/b", `a\b`}
+	for _, key := range tests {
+		t.Run(key, func(t *testing.T) {
+			err := store.Put(ctx, key, strings.NewReader("data"))
+			if err == nil {
+				t.Errorf("Put(%q) error = nil, want error", key)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package entities
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	TypeHashtag = "hashtag"
+	TypeMention = "mention"
+)
+
+// Entity found in a chirp body. Start and End are character (not byte) offsets
+// into the body, End is exclusive.
+type Entity struct {
+	Type  string
+	Start int
+	End   int
+	// Tag without the #, lowercased, or the mentioned email without the @
+	Value string
+}
+
+var (
+	hashtagPattern = regexp.MustCompile(`#[\p{L}\p{N}_]*\p{L}[\p{L}\p{N}_]*`)
+	mentionPattern = regexp.MustCompile(`@[\p{L}\p{N}._%+-]+@[\p{L}\p{N}-]+(?:\.[\p{L}\p{N}-]+)+`)
+)
+
+// Parse hashtags and mentions from body, in the order they appear
+func Parse(body string) []Entity {
+	found := []Entity{}
+	for _, match := range hashtagPattern.FindAllStringIndex(body, -1) {
+		if !startsWord(body, match[0]) {
+			continue
+		}
+		found = append(found, newEntity(body, TypeHashtag, match[0], match[1]))
+	}
+	for _, match := range mentionPattern.FindAllStringIndex(body, -1) {
+		if !startsWord(body, match[0]) {
+			continue
+		}
+		found = append(found, newEntity(body, TypeMention, match[0], match[1]))
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].Start < found[j].Start })
+	return found
+}
+
+// Unique lowercased hashtags in body
+func Hashtags(body string) []string {
+	return uniqueValues(body, TypeHashtag)
+}
+
+// Unique lowercased emails mentioned in body
+func Mentions(body string) []string {
+	return uniqueValues(body, TypeMention)
+}
+
+func uniqueValues(body, entityType string) []string {
+	seen := map[string]bool{}
+	values := []string{}
+	for _, entity := range Parse(body) {
+		if entity.Type != entityType || seen[entity.Value] {
+			continue
+		}
+		seen[entity.Value] = true
+		values = append(values, entity.Value)
+	}
+	return values
+}
+
+// An entity only counts when it isn't glued to the end of another word, so
+// emails aren't read as mentions of their own domain and "a#b" isn't a tag
+func startsWord(body string, i int) bool {
+	if i == 0 {
+		return true
+	}
+	prev, _ := utf8.DecodeLastRuneInString(body[:i])
+	return !unicode.IsLetter(prev) && !unicode.IsNumber(prev) && prev != '_' && prev != '@' && prev != '#'
+}
+
+func newEntity(body, entityType string, start, end int) Entity {
+	return Entity{
+		Type:  entityType,
+		Start: utf8.RuneCountInString(body[:start]),
+		End:   utf8.RuneCountInString(body[:end]),
+		Value: strings.ToLower(body[start+1 : end]),
+	}
+}
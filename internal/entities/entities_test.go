@@ -0,0 +1,62 @@
+package entities
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []Entity
+	}{
+		{
+			name: "no entities",
+			body: "just a chirp",
+			want: []Entity{},
+		},
+		{
+			name: "hashtag and mention",
+			body: "#GoLang with @Alice@Example.com!",
+			want: []Entity{
+				{Type: TypeHashtag, Start: 0, End: 7, Value: "golang"},
+				{Type: TypeMention, Start: 13, End: 31, Value: "alice@example.com"},
+			},
+		},
+		{
+			name: "offsets count characters",
+			body: "héllo #café",
+			want: []Entity{
+				{Type: TypeHashtag, Start: 6, End: 11, Value: "café"},
+			},
+		},
+		{
+			name: "email is not a mention",
+			body: "mail bob@example.com",
+			want: []Entity{},
+		},
+		{
+			name: "numbers and glued tags are not hashtags",
+			body: "issue #42 and a#b",
+			want: []Entity{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse(tt.body)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHashtags(t *testing.T) {
+	got := Hashtags("#go #Go #sql")
+	want := []string{"go", "sql"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Hashtags() = %v, want %v", got, want)
+	}
+}
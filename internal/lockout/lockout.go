@@ -0,0 +1,85 @@
+package lockout
+
+import (
+	"context"
+	"time"
+)
+
+// Failed attempts for a key, counting starts over once a window passes without a failure
+type Record struct {
+	Failures    int
+	LastFailure time.Time
+}
+
+// Store keeps failure records, shared by every instance when backed by a database
+type Store interface {
+	// Attempt counts a failure at now unless locked says the current record is locked out, starting over if
+	// the last one was before windowStart. The check and the count are one step, so concurrent attempts can't
+	// all get past the check. It returns the record and whether the attempt was counted
+	Attempt(ctx context.Context, key string, now, windowStart time.Time, locked func(Record) bool) (Record, bool, error)
+	// Refund takes back one counted failure
+	Refund(ctx context.Context, key string) error
+	Reset(ctx context.Context, key string) error
+}
+
+// Policy for how many failures are allowed before backing off
+type Policy struct {
+	// failures allowed before any delay
+	FreeAttempts int
+	// delay after the first failure past FreeAttempts, doubling after each one
+	BaseDelay time.Duration
+	// longest a key is locked out for
+	MaxDelay time.Duration
+	// failures are forgotten once this long passes without one, should be longer than MaxDelay
+	Window time.Duration
+}
+
+// Limiter applies a policy to keys in a store
+type Limiter struct {
+	store  Store
+	policy Policy
+	now    func() time.Time
+}
+
+// Create limiter
+func NewLimiter(store Store, policy Policy) *Limiter {
+	return &Limiter{store: store, policy: policy, now: time.Now}
+}
+
+// Count an attempt for key as failed up front, unless key is locked out. Returns how long until key
+// may try again when it is, the attempt isn't counted then. Call Reset or Refund if the attempt succeeds
+func (l *Limiter) Attempt(ctx context.Context, key string) (time.Duration, error) {
+	now := l.now()
+	locked := func(record Record) bool { return l.wait(record, now) > 0 }
+	record, counted, err := l.store.Attempt(ctx, key, now, now.Add(-l.policy.Window), locked)
+	if err != nil || counted {
+		return 0, err
+	}
+	return l.wait(record, now), nil
+}
+
+// Take back an attempt that succeeded, keeping the failures before it
+func (l *Limiter) Refund(ctx context.Context, key string) error {
+	return l.store.Refund(ctx, key)
+}
+
+// Forget every failure for key, e.g. after a successful attempt
+func (l *Limiter) Reset(ctx context.Context, key string) error {
+	return l.store.Reset(ctx, key)
+}
+
+func (l *Limiter) wait(record Record, now time.Time) time.Duration {
+	if now.Sub(record.LastFailure) >= l.policy.Window {
+		return 0
+	}
+	over := record.Failures - l.policy.FreeAttempts
+	if over <= 0 {
+		return 0
+	}
+	delay := l.policy.BaseDelay
+	for i := 1; i < over && delay < l.policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, l.policy.MaxDelay)
+	return max(record.LastFailure.Add(delay).Sub(now), 0)
+}
@@ -0,0 +1,175 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	policy := Policy{
+		FreeAttempts: 3,
+		BaseDelay:    time.Second,
+		MaxDelay:     10 * time.Second,
+		Window:       time.Minute,
+	}
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		failures  int
+		elapsed   time.Duration
+		reset     bool
+		wantCheck time.Duration
+	}{
+		{
+			name:      "free attempts",
+			failures:  3,
+			wantCheck: 0,
+		},
+		{
+			name:      "first delay",
+			failures:  4,
+			wantCheck: time.Second,
+		},
+		{
+			name:      "delay doubles",
+			failures:  6,
+			wantCheck: 4 * time.Second,
+		},
+		{
+			name:      "delay is capped",
+			failures:  20,
+			wantCheck: 10 * time.Second,
+		},
+		{
+			name:      "delay counts down",
+			failures:  6,
+			elapsed:   3 * time.Second,
+			wantCheck: time.Second,
+		},
+		{
+			name:      "window passes",
+			failures:  20,
+			elapsed:   time.Minute,
+			wantCheck: 0,
+		},
+		{
+			name:      "reset after success",
+			failures:  20,
+			reset:     true,
+			wantCheck: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			now := start
+			store := NewMemoryStore()
+			store.records["account:a@example.com"] = Record{Failures: tt.failures, LastFailure: start}
+			limiter := NewLimiter(store, policy)
+			limiter.now = func() time.Time { return now }
+
+			if tt.reset {
+				limiter.Reset(ctx, "account:a@example.com")
+			}
+			now = now.Add(tt.elapsed)
+
+			got, err := limiter.Attempt(ctx, "account:a@example.com")
+			if err != nil {
+				t.Fatalf("Attempt() error = %v", err)
+			}
+			if got != tt.wantCheck {
+				t.Errorf("Attempt() = %v, want %v", got, tt.wantCheck)
+			}
+
+			other, _ := limiter.Attempt(ctx, "account:b@example.com")
+			if other != 0 {
+				t.Errorf("Attempt() other key = %v, want 0", other)
+			}
+		})
+	}
+}
+
+func TestLimiterAttempts(t *testing.T) {
+	policy := Policy{
+		FreeAttempts: 3,
+		BaseDelay:    time.Second,
+		MaxDelay:     10 * time.Second,
+		Window:       time.Minute,
+	}
+	ctx := context.Background()
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	limiter := NewLimiter(store, policy)
+	limiter.now = func() time.Time { return now }
+
+	//a refunded attempt is one that succeeded, so it doesn't count
+	limiter.Attempt(ctx, "key")
+	limiter.Refund(ctx, "key")
+	if got := store.records["key"].Failures; got != 0 {
+		t.Errorf("Failures after refund = %d, want 0", got)
+	}
+
+	for i := 0; i < 10; i++ {
+		limiter.Attempt(ctx, "key")
+	}
+	if got := store.records["key"].Failures; got != policy.FreeAttempts+1 {
+		t.Errorf("Failures = %d, want %d, attempts while locked out aren't counted", got, policy.FreeAttempts+1)
+	}
+	got, _ := limiter.Attempt(ctx, "key")
+	if got != time.Second {
+		t.Errorf("Attempt() = %v, want %v", got, time.Second)
+	}
+}
+
+func TestLimiterConcurrentAttempts(t *testing.T) {
+	policy := Policy{
+		FreeAttempts: 5,
+		BaseDelay:    time.Minute,
+		MaxDelay:     time.Hour,
+		Window:       time.Hour,
+	}
+	limiter := NewLimiter(NewMemoryStore(), policy)
+
+	allowed := atomic.Int32{}
+	wg := sync.WaitGroup{}
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wait, err := limiter.Attempt(context.Background(), "key")
+			if err == nil && wait == 0 {
+				allowed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	//one past the free attempts gets through, it's the failure that starts the lockout
+	if got := allowed.Load(); got != int32(policy.FreeAttempts+1) {
+		t.Errorf("allowed %d concurrent attempts, want %d", got, policy.FreeAttempts+1)
+	}
+}
+
+func TestMemoryStoreWindow(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	unlocked := func(Record) bool { return false }
+
+	store.Attempt(ctx, "key", start, start.Add(-time.Minute), unlocked)
+	record, _, _ := store.Attempt(ctx, "key", start.Add(time.Second), start.Add(time.Second-time.Minute), unlocked)
+	if record.Failures != 2 {
+		t.Errorf("Failures inside window = %d, want 2", record.Failures)
+	}
+
+	later := start.Add(2 * time.Minute)
+	record, _, _ = store.Attempt(ctx, "key", later, later.Add(-time.Minute), unlocked)
+	if record.Failures != 1 {
+		t.Errorf("Failures after window = %d, want 1", record.Failures)
+	}
+}
@@ -0,0 +1,69 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps records in memory, for a single instance
+type MemoryStore struct {
+	mu        sync.Mutex
+	records   map[string]Record
+	lastSweep time.Time
+}
+
+// Create memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: map[string]Record{}}
+}
+
+func (s *MemoryStore) Attempt(ctx context.Context, key string, now, windowStart time.Time, locked func(Record) bool) (Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	//drop records that have aged out so guessed keys don't pile up
+	if now.Sub(s.lastSweep) > now.Sub(windowStart) {
+		for k, record := range s.records {
+			if record.LastFailure.Before(windowStart) {
+				delete(s.records, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	record := s.records[key]
+	if locked(record) {
+		return record, false, nil
+	}
+	if record.LastFailure.Before(windowStart) {
+		record.Failures = 0
+	}
+	record.Failures++
+	record.LastFailure = now
+	s.records[key] = record
+	return record, true, nil
+}
+
+func (s *MemoryStore) Refund(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, ok := s.records[key]
+	if !ok {
+		return nil
+	}
+	record.Failures--
+	if record.Failures <= 0 {
+		delete(s.records, key)
+		return nil
+	}
+	s.records[key] = record
+	return nil
+}
+
+func (s *MemoryStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
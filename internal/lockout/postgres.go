@@ -0,0 +1,86 @@
+package lockout
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/skarsden/Chirp/internal/database"
+)
+
+// PostgresStore keeps records in the login_failures table, shared by every instance
+type PostgresStore struct {
+	queries   *database.Queries
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+// Create postgres store
+func NewPostgresStore(queries *database.Queries) *PostgresStore {
+	return &PostgresStore{queries: queries}
+}
+
+func (s *PostgresStore) Attempt(ctx context.Context, key string, now, windowStart time.Time, locked func(Record) bool) (Record, bool, error) {
+	//timestamps are stored without a zone, so keep them all in UTC
+	now = now.UTC()
+	windowStart = windowStart.UTC()
+
+	err := s.sweep(ctx, now, windowStart)
+	if err != nil {
+		return Record{}, false, err
+	}
+
+	//the failure is only added if the row is still the one that was checked, otherwise check again
+	for {
+		seen, err := s.queries.GetLoginFailure(ctx, key)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return Record{}, false, err
+		}
+		record := Record{Failures: int(seen.Failures), LastFailure: seen.LastFailureAt}
+		if locked(record) {
+			return record, false, nil
+		}
+
+		row, err := s.queries.AddLoginFailure(ctx, database.AddLoginFailureParams{
+			Key:               key,
+			FailedAt:          now,
+			WindowStart:       windowStart,
+			SeenFailures:      seen.Failures,
+			SeenLastFailureAt: seen.LastFailureAt,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			if ctx.Err() != nil {
+				return Record{}, false, ctx.Err()
+			}
+			continue
+		}
+		if err != nil {
+			return Record{}, false, err
+		}
+		return Record{Failures: int(row.Failures), LastFailure: row.LastFailureAt}, true, nil
+	}
+}
+
+func (s *PostgresStore) Refund(ctx context.Context, key string) error {
+	return s.queries.RefundLoginFailure(ctx, key)
+}
+
+func (s *PostgresStore) Reset(ctx context.Context, key string) error {
+	return s.queries.DeleteLoginFailure(ctx, key)
+}
+
+// Delete records that have aged out, at most once a window per instance
+func (s *PostgresStore) sweep(ctx context.Context, now, windowStart time.Time) error {
+	s.mu.Lock()
+	due := now.Sub(s.lastSweep) > now.Sub(windowStart)
+	if due {
+		s.lastSweep = now
+	}
+	s.mu.Unlock()
+	if !due {
+		return nil
+	}
+	return s.queries.DeleteLoginFailuresBefore(ctx, windowStart)
+}
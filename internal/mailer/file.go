@@ -0,0 +1,42 @@
+package mailer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileMailer writes each message to an .eml file in a directory, for local testing
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+// Create file mailer, making the directory if it doesn't exist
+func NewFileMailer(dir, from string) (*FileMailer, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+	return &FileMailer{dir: dir, from: from}, nil
+}
+
+func (f *FileMailer) Send(ctx context.Context, m Message) error {
+	now := time.Now()
+	data, err := m.render(f.from, now)
+	if err != nil {
+		return err
+	}
+
+	//timestamp first so the files sort in the order they were sent
+	suffix := make([]byte, 4)
+	_, err = rand.Read(suffix)
+	if err != nil {
+		return err
+	}
+	name := now.UTC().Format("20060102T150405.000000000") + "-" + hex.EncodeToString(suffix) + ".eml"
+	return os.WriteFile(filepath.Join(f.dir, name), data, 0o644)
+}
@@ -0,0 +1,61 @@
+package mailer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileMailer(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  Message
+		wantErr  bool
+		wantBody string
+	}{
+		{
+			name:     "plain message",
+			message:  Message{To: "alice@example.com", Subject: "Hello", Body: "line one\nline two"},
+			wantBody: "\r\n\r\nline one\r\nline two",
+		},
+		{
+			name:    "header injection",
+			message: Message{To: "alice@example.com\r\nBcc: eve@example.com", Subject: "Hello", Body: "hi"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			m, err := NewFileMailer(dir, "chirpy@example.com")
+			if err != nil {
+				t.Fatalf("NewFileMailer() error = %v", err)
+			}
+
+			err = m.Send(context.Background(), tt.message)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+			if tt.wantErr {
+				if len(files) != 0 {
+					t.Errorf("Send() wrote %d files, want 0", len(files))
+				}
+				return
+			}
+			if len(files) != 1 {
+				t.Fatalf("Send() wrote %d files, want 1", len(files))
+			}
+			data, _ := os.ReadFile(files[0])
+			for _, want := range []string{"From: chirpy@example.com\r\n", "To: alice@example.com\r\n", "Subject: Hello\r\n", tt.wantBody} {
+				if !strings.Contains(string(data), want) {
+					t.Errorf("message %q does not contain %q", data, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package moderation
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Config describes the filter chain, filters run in the order word lists, patterns, links
+type Config struct {
+	WordLists []struct {
+		Words  []string `json:"words"`
+		Action string   `json:"action"`
+	} `json:"word_lists"`
+	Patterns []struct {
+		Pattern string `json:"pattern"`
+		Action  string `json:"action"`
+		Reason  string `json:"reason"`
+	} `json:"patterns"`
+	Links *struct {
+		Allow  []string `json:"allow"`
+		Deny   []string `json:"deny"`
+		Action string   `json:"action"`
+	} `json:"links"`
+}
+
+// Default chain masks the original list of banned words
+func DefaultChain() *Chain {
+	return NewChain(NewWordListFilter([]string{"kerfuffle", "sharbert", "fornax"}, ActionMask))
+}
+
+// Load filter chain from a json config file
+func LoadChain(path string) (*Chain, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := Config{}
+	err = json.Unmarshal(data, &cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid moderation config: %w", err)
+	}
+	return cfg.Build()
+}
+
+// Build filter chain from config
+func (cfg Config) Build() (*Chain, error) {
+	filters := []Filter{}
+	for _, list := range cfg.WordLists {
+		action, err := ParseAction(list.Action)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, NewWordListFilter(list.Words, action))
+	}
+
+	for _, pattern := range cfg.Patterns {
+		action, err := ParseAction(pattern.Action)
+		if err != nil {
+			return nil, err
+		}
+		filter, err := NewRegexFilter(pattern.Pattern, action, pattern.Reason)
+		if err != nil {
+			return nil, fmt.Errorf("invalid moderation pattern %q: %w", pattern.Pattern, err)
+		}
+		filters = append(filters, filter)
+	}
+
+	if cfg.Links != nil {
+		action, err := ParseAction(cfg.Links.Action)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, NewLinkFilter(cfg.Links.Allow, cfg.Links.Deny, action))
+	}
+
+	return NewChain(filters...), nil
+}
@@ -0,0 +1,88 @@
+package moderation
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s]+`)
+
+// LinkFilter checks links in a body against allowed and denied domains.
+// A domain entry also covers its subdomains. When the allow list is empty every
+// domain not on the deny list is allowed.
+type LinkFilter struct {
+	allow  []string
+	deny   []string
+	action Action
+}
+
+// Create link filter
+func NewLinkFilter(allow, deny []string, action Action) *LinkFilter {
+	return &LinkFilter{
+		allow:  normalizeDomains(allow),
+		deny:   normalizeDomains(deny),
+		action: action,
+	}
+}
+
+func normalizeDomains(domains []string) []string {
+	normalized := []string{}
+	for _, domain := range domains {
+		domain = strings.Trim(strings.ToLower(strings.TrimSpace(domain)), ".")
+		if domain != "" {
+			normalized = append(normalized, domain)
+		}
+	}
+	return normalized
+}
+
+func matchesDomain(host string, domains []string) bool {
+	for _, domain := range domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+func linkHost(link string) string {
+	// drop trailing punctuation that is usually part of the sentence, not the link
+	link = strings.TrimRight(link, ".,;:!?)]}'\"")
+	if !strings.Contains(link, "://") {
+		link = "http://" + link
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	return strings.Trim(strings.ToLower(u.Hostname()), ".")
+}
+
+func (f *LinkFilter) blocked(host string) bool {
+	if host == "" || matchesDomain(host, f.deny) {
+		return true
+	}
+	return len(f.allow) > 0 && !matchesDomain(host, f.allow)
+}
+
+// Check links in body
+func (f *LinkFilter) Check(body string) Decision {
+	found := false
+	masked := linkPattern.ReplaceAllStringFunc(body, func(link string) string {
+		if !f.blocked(linkHost(link)) {
+			return link
+		}
+		found = true
+		return Mask
+	})
+
+	if !found {
+		return Decision{Action: ActionAllow}
+	}
+	return Decision{
+		Action: f.action,
+		Body:   masked,
+		Reason: "chirp links to a domain that is not allowed",
+	}
+}
@@ -0,0 +1,83 @@
+package moderation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Mask is written in place of any content a filter masks
+const Mask = "****"
+
+// Action a filter takes when its rule matches a chirp
+type Action int
+
+const (
+	ActionAllow Action = iota
+	ActionMask
+	ActionFlag
+	ActionReject
+)
+
+// Parse action from config ("mask", "flag" or "reject")
+func ParseAction(s string) (Action, error) {
+	switch strings.ToLower(s) {
+	case "mask", "":
+		return ActionMask, nil
+	case "flag":
+		return ActionFlag, nil
+	case "reject":
+		return ActionReject, nil
+	}
+	return ActionAllow, fmt.Errorf("unknown moderation action: %s", s)
+}
+
+// Decision returned by a single filter
+type Decision struct {
+	Action Action
+	// Body with matches masked, only set when Action is ActionMask
+	Body   string
+	Reason string
+}
+
+// Filter inspects a chirp body and decides what to do with it
+type Filter interface {
+	Check(body string) Decision
+}
+
+// Result of running a body through the whole chain
+type Result struct {
+	Body     string
+	Rejected bool
+	Flagged  bool
+	Reasons  []string
+}
+
+// Chain runs filters in order, masking as it goes and stopping at the first rejection
+type Chain struct {
+	filters []Filter
+}
+
+// Create chain of filters
+func NewChain(filters ...Filter) *Chain {
+	return &Chain{filters: filters}
+}
+
+// Moderate chirp body
+func (c *Chain) Moderate(body string) Result {
+	result := Result{Body: body}
+	for _, filter := range c.filters {
+		decision := filter.Check(result.Body)
+		switch decision.Action {
+		case ActionMask:
+			result.Body = decision.Body
+		case ActionFlag:
+			result.Flagged = true
+			result.Reasons = append(result.Reasons, decision.Reason)
+		case ActionReject:
+			result.Rejected = true
+			result.Reasons = []string{decision.Reason}
+			return result
+		}
+	}
+	return result
+}
@@ -0,0 +1,188 @@
+package moderation
+
+import (
+	"testing"
+)
+
+func TestWordListFilter(t *testing.T) {
+	filter := NewWordListFilter([]string{"kerfuffle", "sharbert", "Straße", "café"}, ActionMask)
+
+	tests := []struct {
+		name       string
+		body       string
+		wantAction Action
+		wantBody   string
+	}{
+		{
+			name:       "clean body",
+			body:       "This is a clean chirp",
+			wantAction: ActionAllow,
+		},
+		{
+			name:       "mixed case",
+			body:       "What a KerFuffle that was",
+			wantAction: ActionMask,
+			wantBody:   "What a **** that was",
+		},
+		{
+			name:       "punctuation",
+			body:       "Kerfuffle! Sharbert?",
+			wantAction: ActionMask,
+			wantBody:   "****! ****?",
+		},
+		{
+			name:       "unicode word",
+			body:       "Die STRASSE und die straße",
+			wantAction: ActionMask,
+			wantBody:   "Die **** und die ****",
+		},
+		{
+			name:       "fullwidth letters",
+			body:       "what a ｋｅｒｆｕｆｆｌｅ",
+			wantAction: ActionMask,
+			wantBody:   "what a ****",
+		},
+		{
+			name:       "decomposed combining marks",
+			body:       "no cafe\u0301 here",
+			wantAction: ActionMask,
+			wantBody:   "no **** here",
+		},
+		{
+			name:       "different accent is not a match",
+			body:       "no cafè here",
+			wantAction: ActionAllow,
+		},
+		{
+			name:       "substring is not a match",
+			body:       "kerfufflement",
+			wantAction: ActionAllow,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := filter.Check(tt.body)
+			if got.Action != tt.wantAction {
+				t.Errorf("Check() action = %v, want %v", got.Action, tt.wantAction)
+				return
+			}
+			if tt.wantAction == ActionMask && got.Body != tt.wantBody {
+				t.Errorf("Check() body = %q, want %q", got.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestLinkFilter(t *testing.T) {
+	tests := []struct {
+		name       string
+		filter     *LinkFilter
+		body       string
+		wantAction Action
+	}{
+		{
+			name:       "denied domain",
+			filter:     NewLinkFilter(nil, []string{"spam.com"}, ActionReject),
+			body:       "buy now at https://spam.com/deal",
+			wantAction: ActionReject,
+		},
+		{
+			name:       "denied subdomain",
+			filter:     NewLinkFilter(nil, []string{"spam.com"}, ActionReject),
+			body:       "see www.shop.spam.com.",
+			wantAction: ActionReject,
+		},
+		{
+			name:       "not on deny list",
+			filter:     NewLinkFilter(nil, []string{"spam.com"}, ActionReject),
+			body:       "read https://go.dev/doc",
+			wantAction: ActionAllow,
+		},
+		{
+			name:       "not on allow list",
+			filter:     NewLinkFilter([]string{"go.dev"}, nil, ActionFlag),
+			body:       "read https://example.com",
+			wantAction: ActionFlag,
+		},
+		{
+			name:       "on allow list",
+			filter:     NewLinkFilter([]string{"go.dev"}, nil, ActionFlag),
+			body:       "read https://pkg.go.dev/net/http",
+			wantAction: ActionAllow,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.filter.Check(tt.body)
+			if got.Action != tt.wantAction {
+				t.Errorf("Check() action = %v, want %v", got.Action, tt.wantAction)
+			}
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	regex, err := NewRegexFilter(`\d{3}-\d{4}`, ActionFlag, "phone number")
+	if err != nil {
+		t.Fatalf("NewRegexFilter() error = %v", err)
+	}
+	chain := NewChain(
+		NewWordListFilter([]string{"fornax"}, ActionMask),
+		regex,
+		NewLinkFilter(nil, []string{"spam.com"}, ActionReject),
+	)
+
+	tests := []struct {
+		name         string
+		body         string
+		wantBody     string
+		wantRejected bool
+		wantFlagged  bool
+	}{
+		{
+			name:     "clean",
+			body:     "hello world",
+			wantBody: "hello world",
+		},
+		{
+			name:        "mask and flag",
+			body:        "Fornax, call 555-1234",
+			wantBody:    "****, call 555-1234",
+			wantFlagged: true,
+		},
+		{
+			name:     "bare domain is not a link",
+			body:     "fornax spam.com",
+			wantBody: "**** spam.com",
+		},
+		{
+			name:         "reject link",
+			body:         "fornax http://spam.com",
+			wantRejected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := chain.Moderate(tt.body)
+			if got.Rejected != tt.wantRejected {
+				t.Errorf("Moderate() rejected = %v, want %v", got.Rejected, tt.wantRejected)
+				return
+			}
+			if got.Rejected {
+				if len(got.Reasons) != 1 {
+					t.Errorf("Moderate() reasons = %v, want one reason", got.Reasons)
+				}
+				return
+			}
+			if got.Flagged != tt.wantFlagged {
+				t.Errorf("Moderate() flagged = %v, want %v", got.Flagged, tt.wantFlagged)
+			}
+			if got.Body != tt.wantBody {
+				t.Errorf("Moderate() body = %q, want %q", got.Body, tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package moderation
+
+import (
+	"regexp"
+)
+
+// RegexFilter matches bodies against a regular expression
+type RegexFilter struct {
+	pattern *regexp.Regexp
+	action  Action
+	reason  string
+}
+
+// Create regex filter, reason is reported back when the filter flags or rejects
+func NewRegexFilter(pattern string, action Action, reason string) (*RegexFilter, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if reason == "" {
+		reason = "chirp matches blocked pattern"
+	}
+	return &RegexFilter{pattern: re, action: action, reason: reason}, nil
+}
+
+// Check body against pattern
+func (f *RegexFilter) Check(body string) Decision {
+	if !f.pattern.MatchString(body) {
+		return Decision{Action: ActionAllow}
+	}
+	return Decision{
+		Action: f.action,
+		Body:   f.pattern.ReplaceAllLiteralString(body, Mask),
+		Reason: f.reason,
+	}
+}
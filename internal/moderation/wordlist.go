@@ -0,0 +1,78 @@
+package moderation
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// WordListFilter matches whole words regardless of case, surrounding punctuation, script or
+// Unicode form, so fullwidth or decomposed spellings of a banned word still match
+type WordListFilter struct {
+	words  map[string]struct{}
+	action Action
+}
+
+// Create word list filter
+func NewWordListFilter(words []string, action Action) *WordListFilter {
+	set := make(map[string]struct{}, len(words))
+	fold := cases.Fold()
+	for _, word := range words {
+		word = foldWord(fold, strings.TrimSpace(word))
+		if word != "" {
+			set[word] = struct{}{}
+		}
+	}
+	return &WordListFilter{words: set, action: action}
+}
+
+// Bring a word to one comparable form, NFKC turns compatibility and decomposed characters into
+// their plain composed ones before case folding
+func foldWord(fold cases.Caser, word string) string {
+	return norm.NFKC.String(fold.String(norm.NFKC.String(word)))
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.Is(unicode.Mn, r)
+}
+
+// Check body for banned words
+func (f *WordListFilter) Check(body string) Decision {
+	var masked strings.Builder
+	found := ""
+	//casers keep state, so each check gets its own
+	fold := cases.Fold()
+	runes := []rune(body)
+	for i := 0; i < len(runes); {
+		if !isWordRune(runes[i]) {
+			masked.WriteRune(runes[i])
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && isWordRune(runes[j]) {
+			j++
+		}
+		word := string(runes[i:j])
+		if _, ok := f.words[foldWord(fold, word)]; ok {
+			if found == "" {
+				found = word
+			}
+			masked.WriteString(Mask)
+		} else {
+			masked.WriteString(word)
+		}
+		i = j
+	}
+
+	if found == "" {
+		return Decision{Action: ActionAllow}
+	}
+	return Decision{
+		Action: f.action,
+		Body:   masked.String(),
+		Reason: "chirp contains banned word",
+	}
+}
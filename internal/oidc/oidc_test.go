@@ -0,0 +1,216 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Minimal OIDC provider: discovery, JWKS and a token endpoint handing out one code
+type mockProvider struct {
+	server    *httptest.Server
+	key       ed25519.PrivateKey
+	code      string
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	m := &mockProvider{key: key, code: "the-code"}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.server.URL,
+			"authorization_endpoint": m.server.URL + "/authorize",
+			"token_endpoint":         m.server.URL + "/token",
+			"jwks_uri":               m.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "OKP",
+			"kid": "k1",
+			"use": "sig",
+			"crv": "Ed25519",
+			"x":   base64.RawURLEncoding.EncodeToString(m.key.Public().(ed25519.PublicKey)),
+		}}})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		if user != "chirpy" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			return
+		}
+		if r.FormValue("code") != m.code || CodeChallenge(r.FormValue("code_verifier")) != m.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, m.claims)
+		token.Header["kid"] = "k1"
+		idToken, err := token.SignedString(m.key)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "unused", "token_type": "Bearer", "id_token": idToken})
+	})
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+func TestExchange(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		code     string
+		verifier string
+		claims   func(issuer string) jwt.MapClaims
+		wantErr  bool
+	}{
+		{
+			name:     "valid",
+			code:     "the-code",
+			verifier: "verifier",
+			claims: func(issuer string) jwt.MapClaims {
+				return jwt.MapClaims{"iss": issuer, "aud": "chirpy", "sub": "123", "iat": now.Unix(), "exp": now.Add(time.Minute).Unix(), "nonce": "n", "email": "alice@example.com", "email_verified": true}
+			},
+		},
+		{
+			name:     "wrong verifier",
+			code:     "the-code",
+			verifier: "other",
+			claims: func(issuer string) jwt.MapClaims {
+				return jwt.MapClaims{"iss": issuer, "aud": "chirpy", "sub": "123", "exp": now.Add(time.Minute).Unix(), "nonce": "n"}
+			},
+			wantErr: true,
+		},
+		{
+			name:     "wrong code",
+			code:     "guess",
+			verifier: "verifier",
+			claims: func(issuer string) jwt.MapClaims {
+				return jwt.MapClaims{"iss": issuer, "aud": "chirpy", "sub": "123", "exp": now.Add(time.Minute).Unix(), "nonce": "n"}
+			},
+			wantErr: true,
+		},
+		{
+			name:     "wrong nonce",
+			code:     "the-code",
+			verifier: "verifier",
+			claims: func(issuer string) jwt.MapClaims {
+				return jwt.MapClaims{"iss": issuer, "aud": "chirpy", "sub": "123", "exp": now.Add(time.Minute).Unix(), "nonce": "replayed"}
+			},
+			wantErr: true,
+		},
+		{
+			name:     "wrong audience",
+			code:     "the-code",
+			verifier: "verifier",
+			claims: func(issuer string) jwt.MapClaims {
+				return jwt.MapClaims{"iss": issuer, "aud": "someone-else", "sub": "123", "exp": now.Add(time.Minute).Unix(), "nonce": "n"}
+			},
+			wantErr: true,
+		},
+		{
+			name:     "wrong issuer",
+			code:     "the-code",
+			verifier: "verifier",
+			claims: func(issuer string) jwt.MapClaims {
+				return jwt.MapClaims{"iss": "https://evil.example.com", "aud": "chirpy", "sub": "123", "exp": now.Add(time.Minute).Unix(), "nonce": "n"}
+			},
+			wantErr: true,
+		},
+		{
+			name:     "expired",
+			code:     "the-code",
+			verifier: "verifier",
+			claims: func(issuer string) jwt.MapClaims {
+				return jwt.MapClaims{"iss": issuer, "aud": "chirpy", "sub": "123", "exp": now.Add(-time.Minute).Unix(), "nonce": "n"}
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockProvider(t)
+			m.challenge = CodeChallenge("verifier")
+			m.claims = tt.claims(m.server.URL)
+
+			p, err := Discover(context.Background(), Config{
+				Issuer:       m.server.URL,
+				ClientID:     "chirpy",
+				ClientSecret: "secret",
+				RedirectURL:  "http://localhost:8080/api/login/oidc/callback",
+			}, m.server.Client())
+			if err != nil {
+				t.Fatalf("Discover() error = %v", err)
+			}
+
+			claims, err := p.Exchange(context.Background(), tt.code, tt.verifier, "n")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Exchange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if claims.Subject != "123" || claims.Email != "alice@example.com" || !claims.EmailVerified {
+				t.Errorf("Exchange() claims = %+v", claims)
+			}
+		})
+	}
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	m := newMockProvider(t)
+	p, err := Discover(context.Background(), Config{Issuer: m.server.URL, ClientID: "chirpy", RedirectURL: "http://localhost/cb"}, m.server.Client())
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+
+	u, err := url.Parse(p.AuthCodeURL("s", "n", "verifier"))
+	if err != nil {
+		t.Fatalf("AuthCodeURL() is not a URL: %v", err)
+	}
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             "chirpy",
+		"redirect_uri":          "http://localhost/cb",
+		"state":                 "s",
+		"nonce":                 "n",
+		"code_challenge":        CodeChallenge("verifier"),
+		"code_challenge_method": "S256",
+	}
+	for k, v := range want {
+		if got := u.Query().Get(k); got != v {
+			t.Errorf("AuthCodeURL() %s = %q, want %q", k, got, v)
+		}
+	}
+}
+
+func TestCodeChallenge(t *testing.T) {
+	//example from RFC 7636 appendix B
+	got := CodeChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk")
+	want := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	if got != want {
+		t.Errorf("CodeChallenge() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,72 @@
+package search
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// Build a postgres tsquery from user input. Quoted text is matched as a phrase,
+// a trailing * makes a word a prefix match and all terms must match. Anything that
+// isn't a letter or digit is dropped so the result is always valid tsquery syntax.
+func BuildQuery(input string) (string, error) {
+	terms := []string{}
+	for _, token := range tokenize(input) {
+		words := splitWords(token.text)
+		if len(words) == 0 {
+			continue
+		}
+		if token.prefix {
+			words[len(words)-1] += ":*"
+		}
+		// several words in one term are matched next to each other
+		terms = append(terms, strings.Join(words, " <-> "))
+	}
+	if len(terms) == 0 {
+		return "", errors.New("search query has no searchable words")
+	}
+	return strings.Join(terms, " & "), nil
+}
+
+type token struct {
+	text   string
+	prefix bool
+}
+
+func tokenize(input string) []token {
+	tokens := []token{}
+	for len(input) > 0 {
+		input = strings.TrimLeftFunc(input, unicode.IsSpace)
+		if input == "" {
+			break
+		}
+
+		if input[0] == '"' {
+			phrase, rest, found := strings.Cut(input[1:], `"`)
+			if !found {
+				rest = ""
+			}
+			tokens = append(tokens, token{text: phrase})
+			input = rest
+			continue
+		}
+
+		end := strings.IndexFunc(input, func(r rune) bool { return unicode.IsSpace(r) || r == '"' })
+		if end == -1 {
+			end = len(input)
+		}
+		word := input[:end]
+		tokens = append(tokens, token{
+			text:   strings.TrimRight(word, "*"),
+			prefix: strings.HasSuffix(word, "*"),
+		})
+		input = input[end:]
+	}
+	return tokens
+}
+
+func splitWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
@@ -0,0 +1,63 @@
+package search
+
+import (
+	"testing"
+)
+
+func TestBuildQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:  "single word",
+			input: "chirpy",
+			want:  "chirpy",
+		},
+		{
+			name:  "all words must match",
+			input: "Go  Postgres",
+			want:  "go & postgres",
+		},
+		{
+			name:  "phrase",
+			input: `"hello world" again`,
+			want:  "hello <-> world & again",
+		},
+		{
+			name:  "prefix",
+			input: "chirp* post",
+			want:  "chirp:* & post",
+		},
+		{
+			name:  "tsquery syntax is stripped",
+			input: "a|b & !(c:*)",
+			want:  "a <-> b & c",
+		},
+		{
+			name:  "unterminated phrase",
+			input: `"hello world`,
+			want:  "hello <-> world",
+		},
+		{
+			name:    "nothing searchable",
+			input:   ` "" * !! `,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := BuildQuery(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("BuildQuery() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("BuildQuery() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCode(t *testing.T) {
+	//RFC 6238 appendix B, SHA1 with the ASCII secret "12345678901234567890"
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	tests := []struct {
+		name string
+		time int64
+		want string
+	}{
+		{name: "59", time: 59, want: "94287082"},
+		{name: "1111111109", time: 1111111109, want: "07081804"},
+		{name: "1111111111", time: 1111111111, want: "14050471"},
+		{name: "1234567890", time: 1234567890, want: "89005924"},
+		{name: "2000000000", time: 2000000000, want: "69279037"},
+		{name: "20000000000", time: 20000000000, want: "65353130"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Code(secret, Step(time.Unix(tt.time, 0)), 8)
+			if err != nil {
+				t.Fatalf("Code() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Code() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret, _ := GenerateSecret()
+	now := time.Unix(1700000000, 0)
+	current, _ := Code(secret, Step(now), Digits)
+	previous, _ := Code(secret, Step(now)-1, Digits)
+	stale, _ := Code(secret, Step(now)-3, Digits)
+
+	tests := []struct {
+		name     string
+		code     string
+		lastStep int64
+		want     bool
+	}{
+		{name: "current code", code: current, want: true},
+		{name: "previous code within skew", code: previous, want: true},
+		{name: "stale code", code: stale, want: false},
+		{name: "replayed code", code: current, lastStep: Step(now), want: false},
+		{name: "wrong length", code: current + "0", want: false},
+		{name: "empty", code: "", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, got := Validate(secret, tt.code, now, tt.lastStep)
+			if got != tt.want {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("Chirpy", "alice@example.com", "JBSWY3DPEHPK3PXP")
+	for _, want := range []string{"otpauth://totp/Chirpy:alice@example.com?", "secret=JBSWY3DPEHPK3PXP", "issuer=Chirpy", "digits=6", "period=30"} {
+		if !strings.Contains(uri, want) {
+			t.Errorf("URI() = %v, missing %v", uri, want)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skarsden/Chirp/internal/auth"
+)
+
+// Header naming the delivery, so receivers can match a request to the delivery log
+const DeliveryHeader = "Webhook-Delivery"
+
+// Delivery statuses, dead deliveries ran out of attempts
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusDead      = "dead"
+)
+
+// How many due deliveries are sent at once
+const batchSize = 20
+
+// Delivery is one attempt to send an event to a subscriber
+type Delivery struct {
+	ID      uuid.UUID
+	URL     string
+	Secret  []byte
+	Payload []byte
+	// Attempts already made before this one
+	Attempts int
+}
+
+// Store queues deliveries, shared by every instance when backed by a database
+type Store interface {
+	// Claim takes up to limit due deliveries, nobody else gets them again before leaseUntil
+	Claim(ctx context.Context, limit int, leaseUntil time.Time) ([]Delivery, error)
+	Succeeded(ctx context.Context, id uuid.UUID, statusCode int) error
+	// Failed records a failed attempt, dead deliveries aren't tried again, the rest are at retryAt
+	Failed(ctx context.Context, id uuid.UUID, statusCode int, errMsg string, dead bool, retryAt time.Time) error
+}
+
+// Policy decides how long to wait for a receiver and when to give up on it
+type Policy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	Timeout     time.Duration
+}
+
+// Eight attempts spread over about two hours
+var DefaultPolicy = Policy{
+	MaxAttempts: 8,
+	BaseDelay:   time.Minute,
+	MaxDelay:    time.Hour,
+	Timeout:     10 * time.Second,
+}
+
+// Wait after the attempt'th failed attempt, doubling each time up to MaxDelay
+func (p Policy) Backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxDelay)
+}
+
+// Dispatcher sends queued deliveries, signed with their subscription's secret
+type Dispatcher struct {
+	store  Store
+	client *http.Client
+	policy Policy
+	now    func() time.Time
+}
+
+// Create dispatcher, client defaults to one with the policy's timeout that doesn't follow redirects
+// or connect to private addresses
+func NewDispatcher(store Store, client *http.Client, policy Policy) *Dispatcher {
+	if client == nil {
+		client = NewClient(policy.Timeout, false)
+	}
+	return &Dispatcher{store: store, client: client, policy: policy, now: time.Now}
+}
+
+// Send due deliveries every interval until ctx is done
+func (d *Dispatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		//keep going while whole batches come back, there may be a backlog
+		for {
+			sent, err := d.DispatchDue(ctx)
+			if err != nil {
+				log.Printf("Couldn't dispatch webhooks: %s\n", err)
+			}
+			if err != nil || sent < batchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Send one batch of due deliveries, returning how many were attempted
+func (d *Dispatcher) DispatchDue(ctx context.Context) (int, error) {
+	//the lease outlasts the request, so a delivery is only picked up again if this instance died sending it
+	leaseUntil := d.now().Add(d.policy.Timeout + time.Minute)
+	deliveries, err := d.store.Claim(ctx, batchSize, leaseUntil)
+	if err != nil {
+		return 0, err
+	}
+
+	wg := sync.WaitGroup{}
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.deliver(ctx, delivery)
+		}()
+	}
+	wg.Wait()
+	return len(deliveries), nil
+}
+
+// Attempt a delivery and record how it went
+func (d *Dispatcher) deliver(ctx context.Context, delivery Delivery) {
+	statusCode, err := d.send(ctx, delivery)
+	if err == nil {
+		err = d.store.Succeeded(ctx, delivery.ID, statusCode)
+		if err != nil {
+			log.Printf("Couldn't record webhook delivery %s: %s\n", delivery.ID, err)
+		}
+		return
+	}
+
+	attempt := delivery.Attempts + 1
+	dead := attempt >= d.policy.MaxAttempts
+	if dead {
+		log.Printf("Giving up on webhook delivery %s after %d attempts: %s\n", delivery.ID, attempt, err)
+	}
+	err = d.store.Failed(ctx, delivery.ID, statusCode, err.Error(), dead, d.now().Add(d.policy.Backoff(attempt)))
+	if err != nil {
+		log.Printf("Couldn't record webhook delivery %s: %s\n", delivery.ID, err)
+	}
+}
+
+// POST the payload, any 2xx response counts as delivered
+func (d *Dispatcher) send(ctx context.Context, delivery Delivery) (int, error) {
+	timestamp := d.now().Unix()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(DeliveryHeader, delivery.ID.String())
+	req.Header.Set(auth.WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(auth.WebhookSignatureHeader, auth.SignWebhook(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
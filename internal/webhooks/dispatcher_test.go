@@ -0,0 +1,240 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skarsden/Chirp/internal/auth"
+)
+
+// In memory store, hands out every pending delivery whose retry time has come
+type fakeStore struct {
+	mu         sync.Mutex
+	deliveries map[uuid.UUID]*fakeDelivery
+}
+
+type fakeDelivery struct {
+	Delivery
+	status     string
+	retryAt    time.Time
+	statusCode int
+	lastError  string
+}
+
+func newFakeStore(deliveries ...Delivery) *fakeStore {
+	s := &fakeStore{deliveries: map[uuid.UUID]*fakeDelivery{}}
+	for _, d := range deliveries {
+		s.deliveries[d.ID] = &fakeDelivery{Delivery: d, status: StatusPending}
+	}
+	return s
+}
+
+func (s *fakeStore) Claim(ctx context.Context, limit int, leaseUntil time.Time) ([]Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	claimed := []Delivery{}
+	for _, d := range s.deliveries {
+		if d.status == StatusPending && !d.retryAt.After(time.Now()) && len(claimed) < limit {
+			d.retryAt = leaseUntil
+			claimed = append(claimed, d.Delivery)
+		}
+	}
+	return claimed, nil
+}
+
+func (s *fakeStore) Succeeded(ctx context.Context, id uuid.UUID, statusCode int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d := s.deliveries[id]
+	d.Attempts++
+	d.status = StatusSucceeded
+	d.statusCode = statusCode
+	return nil
+}
+
+func (s *fakeStore) Failed(ctx context.Context, id uuid.UUID, statusCode int, errMsg string, dead bool, retryAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d := s.deliveries[id]
+	d.Attempts++
+	d.status = StatusPending
+	if dead {
+		d.status = StatusDead
+	}
+	d.retryAt = retryAt
+	d.statusCode = statusCode
+	d.lastError = errMsg
+	return nil
+}
+
+func TestDispatchSigned(t *testing.T) {
+	payload := []byte(`{"event":"chirp.created"}`)
+	verifier, err := auth.NewWebhookVerifier([]string{"shh"}, time.Minute)
+	if err != nil {
+		t.Fatalf("NewWebhookVerifier() error = %v", err)
+	}
+
+	received := make(chan error, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- verifier.Verify(r.Header, body, time.Now())
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	delivery := Delivery{ID: uuid.New(), URL: receiver.URL, Secret: []byte("shh"), Payload: payload}
+	store := newFakeStore(delivery)
+	d := NewDispatcher(store, receiver.Client(), DefaultPolicy)
+
+	sent, err := d.DispatchDue(context.Background())
+	if err != nil || sent != 1 {
+		t.Fatalf("DispatchDue() = %d, %v, want 1, nil", sent, err)
+	}
+	if err := <-received; err != nil {
+		t.Errorf("receiver couldn't verify signature: %v", err)
+	}
+	got := store.deliveries[delivery.ID]
+	if got.status != StatusSucceeded || got.statusCode != http.StatusNoContent || got.Attempts != 1 {
+		t.Errorf("delivery = %s %d after %d attempts, want succeeded 204 after 1", got.status, got.statusCode, got.Attempts)
+	}
+}
+
+func TestDispatchRetries(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	policy := Policy{MaxAttempts: 3, BaseDelay: time.Minute, MaxDelay: time.Hour, Timeout: time.Second}
+
+	tests := []struct {
+		name        string
+		status      int
+		attempts    int
+		wantStatus  string
+		wantRetryIn time.Duration
+	}{
+		{name: "first failure", status: http.StatusInternalServerError, attempts: 0, wantStatus: StatusPending, wantRetryIn: time.Minute},
+		{name: "second failure backs off", status: http.StatusBadGateway, attempts: 1, wantStatus: StatusPending, wantRetryIn: 2 * time.Minute},
+		{name: "out of attempts", status: http.StatusInternalServerError, attempts: 2, wantStatus: StatusDead},
+		{name: "redirects aren't followed", status: http.StatusFound, attempts: 0, wantStatus: StatusPending, wantRetryIn: time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+			}))
+			defer receiver.Close()
+
+			delivery := Delivery{ID: uuid.New(), URL: receiver.URL, Secret: []byte("shh"), Payload: []byte(`{}`), Attempts: tt.attempts}
+			store := newFakeStore(delivery)
+			client := receiver.Client()
+			client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+			d := NewDispatcher(store, client, policy)
+			d.now = func() time.Time { return now }
+
+			_, err := d.DispatchDue(context.Background())
+			if err != nil {
+				t.Fatalf("DispatchDue() error = %v", err)
+			}
+			got := store.deliveries[delivery.ID]
+			if got.status != tt.wantStatus || got.statusCode != tt.status || got.lastError == "" {
+				t.Errorf("delivery = %s %d %q, want %s %d with an error", got.status, got.statusCode, got.lastError, tt.wantStatus, tt.status)
+			}
+			if tt.wantStatus == StatusPending && got.retryAt.Sub(now) != tt.wantRetryIn {
+				t.Errorf("retry in %s, want %s", got.retryAt.Sub(now), tt.wantRetryIn)
+			}
+		})
+	}
+}
+
+func TestDispatchUnreachable(t *testing.T) {
+	receiver := httptest.NewServer(http.NotFoundHandler())
+	url := receiver.URL
+	receiver.Close()
+
+	delivery := Delivery{ID: uuid.New(), URL: url, Secret: []byte("shh"), Payload: []byte(`{}`)}
+	store := newFakeStore(delivery)
+	d := NewDispatcher(store, NewClient(time.Second, true), Policy{MaxAttempts: 1, BaseDelay: time.Minute, MaxDelay: time.Hour, Timeout: time.Second})
+
+	_, err := d.DispatchDue(context.Background())
+	if err != nil {
+		t.Fatalf("DispatchDue() error = %v", err)
+	}
+	got := store.deliveries[delivery.ID]
+	if got.status != StatusDead || got.statusCode != 0 || got.lastError == "" {
+		t.Errorf("delivery = %s %d %q, want dead with no status and an error", got.status, got.statusCode, got.lastError)
+	}
+}
+
+func TestDispatchPrivateTarget(t *testing.T) {
+	called := false
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	//the default client won't connect to the loopback address the test server listens on
+	delivery := Delivery{ID: uuid.New(), URL: receiver.URL, Secret: []byte("shh"), Payload: []byte(`{}`)}
+	store := newFakeStore(delivery)
+	d := NewDispatcher(store, nil, Policy{MaxAttempts: 1, BaseDelay: time.Minute, MaxDelay: time.Hour, Timeout: time.Second})
+
+	_, err := d.DispatchDue(context.Background())
+	if err != nil {
+		t.Fatalf("DispatchDue() error = %v", err)
+	}
+	got := store.deliveries[delivery.ID]
+	if called || got.status != StatusDead || !strings.Contains(got.lastError, ErrPrivateTarget.Error()) {
+		t.Errorf("delivery = %s %q, called %v, want dead with a private target error and not called", got.status, got.lastError, called)
+	}
+}
+
+func TestCheckTarget(t *testing.T) {
+	tests := []struct {
+		url         string
+		wantPrivate bool
+	}{
+		{url: "https://93.184.215.14/hooks", wantPrivate: false},
+		{url: "http://127.0.0.1:8080/hooks", wantPrivate: true},
+		{url: "https://10.1.2.3/hooks", wantPrivate: true},
+		{url: "https://192.168.0.10/hooks", wantPrivate: true},
+		{url: "http://169.254.169.254/latest/meta-data", wantPrivate: true},
+		{url: "https://[::1]/hooks", wantPrivate: true},
+		{url: "https://[fe80::1]/hooks", wantPrivate: true},
+		{url: "https://0.0.0.0/hooks", wantPrivate: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			err := CheckTarget(context.Background(), tt.url)
+			if got := errors.Is(err, ErrPrivateTarget); got != tt.wantPrivate {
+				t.Errorf("CheckTarget() error = %v, want private %v", err, tt.wantPrivate)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := Policy{BaseDelay: time.Minute, MaxDelay: 10 * time.Minute}
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{4, 8 * time.Minute},
+		{5, 10 * time.Minute},
+		{50, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := p.Backoff(tt.attempt); got != tt.want {
+			t.Errorf("Backoff(%d) = %s, want %s", tt.attempt, got, tt.want)
+		}
+	}
+}
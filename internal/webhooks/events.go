@@ -0,0 +1,45 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Events integrators can subscribe to
+const (
+	EventChirpCreated   = "chirp.created"
+	EventChirpDeleted   = "chirp.deleted"
+	EventUserUpgraded   = "user.upgraded"
+	EventUserDowngraded = "user.downgraded"
+)
+
+// Subscribes to every event, including ones added later
+const AllEvents = "*"
+
+var Events = []string{EventChirpCreated, EventChirpDeleted, EventUserUpgraded, EventUserDowngraded}
+
+// Check whether subscribing to event makes sense
+func ValidEvent(event string) bool {
+	return event == AllEvents || slices.Contains(Events, event)
+}
+
+// Envelope every event is sent in, the id is the same for each subscriber's delivery
+type Envelope struct {
+	ID        uuid.UUID `json:"id"`
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// Wrap data in a new envelope and encode it
+func NewPayload(event string, data any) ([]byte, error) {
+	return json.Marshal(Envelope{
+		ID:        uuid.New(),
+		Event:     event,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+}
@@ -0,0 +1,23 @@
+package webhooks
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/skarsden/Chirp/internal/auth"
+)
+
+// Key for an incoming event in the ledger, deliveries with the same key are only applied once.
+// The provider's id is used when it sends one. Otherwise the key comes from the event and its
+// data alone, so a repeat of the same body is always a duplicate however it was delivered
+func InboundEventID(id, event string, data json.RawMessage) string {
+	if id != "" {
+		return id
+	}
+	compact := bytes.Buffer{}
+	if err := json.Compact(&compact, data); err != nil {
+		compact.Reset()
+		compact.Write(data)
+	}
+	return "sha256:" + auth.HashToken(event+"."+compact.String())
+}
@@ -0,0 +1,42 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestInboundEventID(t *testing.T) {
+	upgraded := json.RawMessage(`{"user_id":"3311741c-680c-4546-99f3-fc9efac2036c"}`)
+	spaced := json.RawMessage(`{ "user_id": "3311741c-680c-4546-99f3-fc9efac2036c" }`)
+	otherUser := json.RawMessage(`{"user_id":"9b1f7f54-0c4e-4a53-8f0e-2f1c3b0e6d11"}`)
+
+	tests := []struct {
+		name     string
+		id       string
+		event    string
+		data     json.RawMessage
+		wantSame bool
+		wantID   string
+	}{
+		{name: "same body twice", event: "user.upgraded", data: upgraded, wantSame: true},
+		{name: "same body with other spacing", event: "user.upgraded", data: spaced, wantSame: true},
+		{name: "other event", event: "user.downgraded", data: upgraded},
+		{name: "other user", event: "user.upgraded", data: otherUser},
+		{name: "provider id", id: "evt_123", event: "user.upgraded", data: upgraded, wantID: "evt_123"},
+	}
+	first := InboundEventID("", "user.upgraded", upgraded)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := InboundEventID(tt.id, tt.event, tt.data)
+			if tt.wantID != "" {
+				if got != tt.wantID {
+					t.Errorf("InboundEventID() = %q, want %q", got, tt.wantID)
+				}
+				return
+			}
+			if (got == first) != tt.wantSame {
+				t.Errorf("InboundEventID() = %q, first delivery %q, want same %v", got, first, tt.wantSame)
+			}
+		})
+	}
+}
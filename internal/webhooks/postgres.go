@@ -0,0 +1,76 @@
+package webhooks
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skarsden/Chirp/internal/auth"
+	"github.com/skarsden/Chirp/internal/database"
+)
+
+// PostgresStore keeps deliveries in the webhook_deliveries table, shared by every instance
+type PostgresStore struct {
+	queries *database.Queries
+	secrets *auth.SecretBox
+}
+
+// Create postgres store, secrets decrypts the subscriptions' signing secrets
+func NewPostgresStore(queries *database.Queries, secrets *auth.SecretBox) *PostgresStore {
+	return &PostgresStore{queries: queries, secrets: secrets}
+}
+
+func (s *PostgresStore) Claim(ctx context.Context, limit int, leaseUntil time.Time) ([]Delivery, error) {
+	rows, err := s.queries.ClaimWebhookDeliveries(ctx, database.ClaimWebhookDeliveriesParams{
+		LeaseUntil: leaseUntil.UTC(),
+		Limit:      int32(limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries := make([]Delivery, 0, len(rows))
+	for _, row := range rows {
+		secret, err := s.secrets.Open(row.EncryptedSecret)
+		if err != nil {
+			//an unsigned delivery would be rejected anyway, so it goes straight to dead letters
+			log.Printf("Couldn't decrypt secret for webhook delivery %s: %s\n", row.ID, err)
+			err = s.Failed(ctx, row.ID, 0, "couldn't decrypt subscription secret", true, leaseUntil)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		deliveries = append(deliveries, Delivery{
+			ID:       row.ID,
+			URL:      row.Url,
+			Secret:   []byte(secret),
+			Payload:  row.Payload,
+			Attempts: int(row.Attempts),
+		})
+	}
+	return deliveries, nil
+}
+
+func (s *PostgresStore) Succeeded(ctx context.Context, id uuid.UUID, statusCode int) error {
+	return s.queries.RecordWebhookDeliverySuccess(ctx, database.RecordWebhookDeliverySuccessParams{
+		ID:             id,
+		LastStatusCode: sql.NullInt32{Int32: int32(statusCode), Valid: true},
+	})
+}
+
+func (s *PostgresStore) Failed(ctx context.Context, id uuid.UUID, statusCode int, errMsg string, dead bool, retryAt time.Time) error {
+	status := StatusPending
+	if dead {
+		status = StatusDead
+	}
+	return s.queries.RecordWebhookDeliveryFailure(ctx, database.RecordWebhookDeliveryFailureParams{
+		ID:             id,
+		Status:         status,
+		NextAttemptAt:  retryAt.UTC(),
+		LastStatusCode: sql.NullInt32{Int32: int32(statusCode), Valid: statusCode != 0},
+		LastError:      sql.NullString{String: errMsg, Valid: true},
+	})
+}
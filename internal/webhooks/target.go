@@ -0,0 +1,70 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// A webhook URL pointing inside our own network, where integrators mustn't be able to send requests
+var ErrPrivateTarget = errors.New("webhook target is a loopback, private or link-local address")
+
+// Check whether ip is somewhere webhooks mustn't go
+func privateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified()
+}
+
+// Resolve the URL's host and refuse it if any of its addresses is private, for checking a subscription
+// when it's made. Deliveries are checked again when they're sent, in case the host's addresses change
+func CheckTarget(ctx context.Context, rawURL string) error {
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, target.Hostname())
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if privateIP(addr.IP) {
+			return fmt.Errorf("%w: %s", ErrPrivateTarget, addr.IP)
+		}
+	}
+	return nil
+}
+
+// Refuse to connect to private addresses, checked on the address actually dialed after DNS
+func refusePrivate(network, address string, conn syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || privateIP(ip) {
+		return fmt.Errorf("%w: %s", ErrPrivateTarget, host)
+	}
+	return nil
+}
+
+// Client for sending deliveries, it doesn't follow redirects and only connects to private addresses
+// with allowPrivate, which is for trying out receivers locally
+func NewClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = refusePrivate
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext},
+		//a redirect would take the signed payload somewhere the subscription didn't name
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
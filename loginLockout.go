@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/skarsden/Chirp/internal/lockout"
+)
+
+// Failed logins allowed per account before backing off
+var loginAccountPolicy = lockout.Policy{
+	FreeAttempts: 5,
+	BaseDelay:    time.Second,
+	MaxDelay:     15 * time.Minute,
+	Window:       time.Hour,
+}
+
+// Failed logins allowed per client IP, higher since many users can share one
+var loginIPPolicy = lockout.Policy{
+	FreeAttempts: 20,
+	BaseDelay:    time.Second,
+	MaxDelay:     15 * time.Minute,
+	Window:       time.Hour,
+}
+
+// Limiter and key for each thing a login attempt is counted against
+type loginLockout struct {
+	limiter *lockout.Limiter
+	key     string
+	//cleared by a successful login, the rest only take back that login's attempt
+	resetOnSuccess bool
+}
+
+func (cfg *apiConfig) loginLockouts(r *http.Request, email string) []loginLockout {
+	return []loginLockout{
+		{limiter: cfg.loginAccountLimiter, key: "account:" + normalizeEmail(email), resetOnSuccess: true},
+		//one account's owner signing in mustn't clear failures others guessed from the same IP
+		{limiter: cfg.loginIPLimiter, key: "ip:" + clientIP(r)},
+	}
+}
+
+// Count a login attempt against every lockout as failed until it succeeds, returning how long until
+// the next attempt is allowed when one is locked out, nothing is counted then
+func attemptLoginLockouts(ctx context.Context, lockouts []loginLockout) (time.Duration, error) {
+	for i, l := range lockouts {
+		wait, err := l.limiter.Attempt(ctx, l.key)
+		if err == nil && wait == 0 {
+			continue
+		}
+		//take back what was already counted so a refused attempt doesn't count anywhere
+		for _, counted := range lockouts[:i] {
+			refundErr := counted.limiter.Refund(ctx, counted.key)
+			if refundErr != nil {
+				log.Printf("Couldn't refund login attempt: %s\n", refundErr)
+			}
+		}
+		return wait, err
+	}
+	return 0, nil
+}
+
+// Take back a successful login's attempt, clearing the lockouts that reset on success
+func succeedLoginLockouts(ctx context.Context, lockouts []loginLockout) error {
+	for _, l := range lockouts {
+		var err error
+		if l.resetOnSuccess {
+			err = l.limiter.Reset(ctx, l.key)
+		} else {
+			err = l.limiter.Refund(ctx, l.key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Take back a login attempt that wasn't a failure but didn't finish signing in either
+func refundLoginLockouts(ctx context.Context, lockouts []loginLockout) error {
+	for _, l := range lockouts {
+		err := l.limiter.Refund(ctx, l.key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Respond to a locked out login with how long to wait
+func respondTooManyAttempts(w http.ResponseWriter, wait time.Duration) {
+	seconds := int64(math.Ceil(wait.Seconds()))
+	w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+	respondWithProblem(w, newProblem(http.StatusTooManyRequests, "too_many_attempts", "Too many failed logins, try again later").
+		with("retry_after", seconds))
+}
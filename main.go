@@ -1,24 +1,52 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
 	"database/sql"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"github.com/skarsden/Chirp/internal/auth"
+	"github.com/skarsden/Chirp/internal/blobstore"
 	"github.com/skarsden/Chirp/internal/database"
+	"github.com/skarsden/Chirp/internal/lockout"
+	"github.com/skarsden/Chirp/internal/mailer"
+	"github.com/skarsden/Chirp/internal/moderation"
+	"github.com/skarsden/Chirp/internal/oidc"
+	"github.com/skarsden/Chirp/internal/webhooks"
 )
 
 type apiConfig struct {
-	fileServerHits atomic.Int32
-	queries        *database.Queries
-	platform       string
-	secret         string
-	polka_key      string
+	fileServerHits      atomic.Int32
+	db                  *sql.DB
+	queries             *database.Queries
+	moderator           *moderation.Chain
+	blobs               blobstore.BlobStore
+	mediaMaxBytes       int64
+	mediaMaxBytesRed    int64
+	platform            string
+	jwtKeys             *auth.KeySet
+	loginAccountLimiter *lockout.Limiter
+	loginIPLimiter      *lockout.Limiter
+	emailLimiter        *lockout.Limiter
+	emailIPLimiter      *lockout.Limiter
+	mailer              mailer.Mailer
+	secretBox           *auth.SecretBox
+	oidc                *oidc.Provider
+	appURL              string
+	adminKey            string
+	webhooksKey         string
+	polka_key           string
+	polkaVerifier       *auth.WebhookVerifier
 }
 
 func main() {
@@ -26,8 +54,43 @@ func main() {
 	godotenv.Load()
 	dbUrl := os.Getenv("DB_URL")
 	platform := os.Getenv("PLATFORM")
-	secret := os.Getenv("SECRET")
+	jwtKeysPath := os.Getenv("JWT_KEYS")
+	jwtKeyGrace := envDuration("JWT_KEY_GRACE", time.Hour)
+	loginLockoutStore := os.Getenv("LOGIN_LOCKOUT_STORE")
+	smtpAddr := os.Getenv("SMTP_ADDR")
+	mailFrom := os.Getenv("MAIL_FROM")
+	if mailFrom == "" {
+		mailFrom = "chirpy@localhost"
+	}
+	mailDir := os.Getenv("MAIL_DIR")
+	if mailDir == "" {
+		mailDir = "mail"
+	}
+	totpEncryptionKey := os.Getenv("TOTP_ENCRYPTION_KEY")
+	appURL := strings.TrimSuffix(os.Getenv("APP_URL"), "/")
+	if appURL == "" {
+		appURL = "http://localhost:8080/app"
+	}
+	oidcIssuer := os.Getenv("OIDC_ISSUER")
+	oidcRedirectURL := os.Getenv("OIDC_REDIRECT_URL")
+	if oidcRedirectURL == "" {
+		oidcRedirectURL = "http://localhost:8080/api/login/oidc/callback"
+	}
 	polka_key := os.Getenv("POLKA_KEY")
+	polkaWebhookAuth := os.Getenv("POLKA_WEBHOOK_AUTH")
+	polkaWebhookSecrets := os.Getenv("POLKA_WEBHOOK_SECRETS")
+	polkaWebhookTolerance := envDuration("POLKA_WEBHOOK_TOLERANCE", 5*time.Minute)
+	adminKey := os.Getenv("ADMIN_API_KEY")
+	webhooksKey := os.Getenv("WEBHOOKS_API_KEY")
+	webhookDispatchInterval := envDuration("WEBHOOK_DISPATCH_INTERVAL", 5*time.Second)
+	moderationConfig := os.Getenv("MODERATION_CONFIG")
+	mediaDir := os.Getenv("MEDIA_DIR")
+	if mediaDir == "" {
+		mediaDir = "media"
+	}
+	mediaMaxBytes := envInt64("MEDIA_MAX_BYTES", 1<<20)
+	mediaMaxBytesRed := envInt64("MEDIA_MAX_BYTES_RED", 5<<20)
+	mediaUnattachedTTL := envDuration("MEDIA_UNATTACHED_TTL", 24*time.Hour)
 
 	//open db connection
 	db, err := sql.Open("postgres", dbUrl)
@@ -36,44 +99,243 @@ func main() {
 	}
 	dbQueries := database.New(db)
 
+	//build moderation filter chain, falling back to the default word list
+	moderator := moderation.DefaultChain()
+	if moderationConfig != "" {
+		moderator, err = moderation.LoadChain(moderationConfig)
+		if err != nil {
+			log.Fatalf("Error loading moderation config: %s", err)
+		}
+	}
+
+	//uploaded media is kept on local disk
+	blobs, err := blobstore.NewFileStore(mediaDir)
+	if err != nil {
+		log.Fatalf("Error opening media directory: %s", err)
+	}
+
+	//access tokens are signed with the active key from the key set file
+	var jwtKeys *auth.KeySet
+	if jwtKeysPath != "" {
+		jwtKeys, err = auth.LoadKeySet(jwtKeysPath, jwtKeyGrace)
+		if err != nil {
+			log.Fatalf("Error loading JWT keys: %s", err)
+		}
+	} else if platform == "dev" {
+		log.Printf("JWT_KEYS not set, signing with a temporary key. Tokens won't survive a restart")
+		key, err := auth.GenerateSigningKey("dev")
+		if err != nil {
+			log.Fatalf("Error generating JWT key: %s", err)
+		}
+		jwtKeys, _ = auth.NewKeySet(key, jwtKeyGrace)
+	} else {
+		log.Fatalf("JWT_KEYS must be set outside of the dev platform")
+	}
+
+	//failed logins are counted in memory unless several instances need to share them
+	var lockoutStore lockout.Store
+	switch loginLockoutStore {
+	case "", "memory":
+		lockoutStore = lockout.NewMemoryStore()
+	case "postgres":
+		lockoutStore = lockout.NewPostgresStore(dbQueries)
+	default:
+		log.Fatalf("Invalid LOGIN_LOCKOUT_STORE: %s", loginLockoutStore)
+	}
+
+	//two factor and outbound webhook secrets are encrypted at rest with TOTP_ENCRYPTION_KEY
+	var secretBox *auth.SecretBox
+	if totpEncryptionKey != "" {
+		secretBox, err = auth.ParseSecretBox(totpEncryptionKey)
+		if err != nil {
+			log.Fatalf("Invalid TOTP_ENCRYPTION_KEY: %s", err)
+		}
+	} else if platform == "dev" {
+		log.Printf("TOTP_ENCRYPTION_KEY not set, using a temporary key. Two factor auth and webhook subscriptions set up now won't survive a restart")
+		key := make([]byte, 32)
+		_, err = rand.Read(key)
+		if err != nil {
+			log.Fatalf("Error generating TOTP encryption key: %s", err)
+		}
+		secretBox, _ = auth.NewSecretBox(key)
+	} else {
+		log.Fatalf("TOTP_ENCRYPTION_KEY must be set outside of the dev platform")
+	}
+
+	//email goes through SMTP when a server is set, otherwise it's dropped in files for local testing
+	var mail mailer.Mailer
+	if smtpAddr != "" {
+		mail, err = mailer.NewSMTPMailer(smtpAddr, mailFrom, os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"))
+	} else {
+		mail, err = mailer.NewFileMailer(mailDir, mailFrom)
+	}
+	if err != nil {
+		log.Fatalf("Error setting up mailer: %s", err)
+	}
+
+	//sign in with an OpenID Connect provider is only offered when one is configured
+	var oidcProvider *oidc.Provider
+	if oidcIssuer != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		oidcProvider, err = oidc.Discover(ctx, oidc.Config{
+			Issuer:       oidcIssuer,
+			ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			RedirectURL:  oidcRedirectURL,
+		}, nil)
+		cancel()
+		if err != nil {
+			log.Fatalf("Error setting up OIDC provider: %s", err)
+		}
+	}
+
+	//Polka signs its webhooks, the static ApiKey header is only accepted when switched back to it
+	if polkaWebhookAuth == "" {
+		polkaWebhookAuth = "signature"
+		//deployments from before signing only have POLKA_KEY, keep them working until they move over
+		if polkaWebhookSecrets == "" && polka_key != "" {
+			log.Printf("WARNING: POLKA_WEBHOOK_AUTH isn't set and only POLKA_KEY is, falling back to the legacy ApiKey header. Set POLKA_WEBHOOK_SECRETS to verify signatures")
+			polkaWebhookAuth = "api_key"
+		}
+	}
+	var polkaVerifier *auth.WebhookVerifier
+	switch polkaWebhookAuth {
+	case "signature":
+		if polkaWebhookSecrets == "" {
+			if platform != "dev" {
+				log.Fatalf("POLKA_WEBHOOK_SECRETS must be set, or POLKA_WEBHOOK_AUTH=api_key for the legacy ApiKey header")
+			}
+			log.Printf("POLKA_WEBHOOK_SECRETS not set, Polka webhooks are turned off")
+			break
+		}
+		polkaVerifier, err = auth.NewWebhookVerifier(strings.Split(polkaWebhookSecrets, ","), polkaWebhookTolerance)
+		if err != nil {
+			log.Fatalf("Invalid POLKA_WEBHOOK_SECRETS: %s", err)
+		}
+	case "api_key":
+		if polka_key == "" {
+			log.Fatalf("POLKA_KEY must be set when POLKA_WEBHOOK_AUTH=api_key")
+		}
+		log.Printf("Accepting Polka webhooks with the legacy ApiKey header, they can be replayed")
+	default:
+		log.Fatalf("Invalid POLKA_WEBHOOK_AUTH: %s", polkaWebhookAuth)
+	}
+
+	//outbound webhooks are queued in the database and sent in the background, any instance can send them
+	//receivers running locally can only be reached on the dev platform
+	webhookClient := webhooks.NewClient(webhooks.DefaultPolicy.Timeout, platform == "dev")
+	dispatcher := webhooks.NewDispatcher(webhooks.NewPostgresStore(dbQueries, secretBox), webhookClient, webhooks.DefaultPolicy)
+	go dispatcher.Run(context.Background(), webhookDispatchInterval)
+
 	const port = "8080"
 	const root = "."
 
 	//records number of handler calls
 	apiCfg := apiConfig{
-		fileServerHits: atomic.Int32{},
-		queries:        dbQueries,
-		platform:       platform,
-		secret:         secret,
-		polka_key:      polka_key,
+		fileServerHits:      atomic.Int32{},
+		db:                  db,
+		queries:             dbQueries,
+		moderator:           moderator,
+		blobs:               blobs,
+		mediaMaxBytes:       mediaMaxBytes,
+		mediaMaxBytesRed:    mediaMaxBytesRed,
+		platform:            platform,
+		jwtKeys:             jwtKeys,
+		loginAccountLimiter: lockout.NewLimiter(lockoutStore, loginAccountPolicy),
+		loginIPLimiter:      lockout.NewLimiter(lockoutStore, loginIPPolicy),
+		emailLimiter:        lockout.NewLimiter(lockoutStore, emailAccountPolicy),
+		emailIPLimiter:      lockout.NewLimiter(lockoutStore, emailIPPolicy),
+		mailer:              mail,
+		secretBox:           secretBox,
+		oidc:                oidcProvider,
+		appURL:              appURL,
+		adminKey:            adminKey,
+		webhooksKey:         webhooksKey,
+		polka_key:           polka_key,
+		polkaVerifier:       polkaVerifier,
 	}
 
+	//uploads that never make it onto a chirp are removed after MEDIA_UNATTACHED_TTL
+	go func() {
+		for range time.Tick(time.Hour) {
+			err := apiCfg.deleteUnattachedMedia(context.Background(), mediaUnattachedTTL)
+			if err != nil {
+				log.Printf("Couldn't delete unattached media: %s\n", err)
+			}
+		}
+	}()
+
 	//Declare handler and register handler functions
 	mux := http.NewServeMux()
 	mux.Handle("/app/", apiCfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(root)))))
+	mux.HandleFunc("GET /media/{mediaID}", apiCfg.handlerServeMedia)
 
 	//meta endpoints
+	mux.HandleFunc("GET /.well-known/jwks.json", apiCfg.handlerJWKS)
 	mux.HandleFunc("GET /api/ready", handlerReady)
 	mux.HandleFunc("GET /admin/metrics", apiCfg.handlerMetrics)
 	mux.HandleFunc("POST /admin/reset", apiCfg.handlerReset)
+	mux.Handle("GET /admin/webhooks/events", apiCfg.middlewareAdmin(apiCfg.handlerGetWebhookEvents))
+	mux.Handle("POST /admin/webhooks/events/{eventID}/replay", apiCfg.middlewareAdmin(apiCfg.handlerReplayWebhookEvent))
 
 	//chirp endpoints
-	mux.HandleFunc("POST /api/chirps", apiCfg.handlerPostChirp)
-	mux.HandleFunc("GET /api/chirps", apiCfg.handlerGetChirps)
-	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.handlerGetChirpById)
-	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.handlerDeleteChirpById)
+	mux.Handle("POST /api/chirps", apiCfg.middlewareAuth(apiCfg.handlerPostChirp, auth.ScopeChirpsWrite))
+	mux.Handle("GET /api/chirps", apiCfg.middlewareOptionalAuth(apiCfg.handlerGetChirps))
+	mux.Handle("GET /api/chirps/search", apiCfg.middlewareOptionalAuth(apiCfg.handlerSearchChirps))
+	mux.Handle("GET /api/chirps/{chirpID}", apiCfg.middlewareOptionalAuth(apiCfg.handlerGetChirpById))
+	mux.Handle("PUT /api/chirps/{chirpID}", apiCfg.middlewareAuth(apiCfg.handlerUpdateChirp, auth.ScopeChirpsWrite))
+	mux.Handle("DELETE /api/chirps/{chirpID}", apiCfg.middlewareAuth(apiCfg.handlerDeleteChirpById, auth.ScopeChirpsWrite))
+	mux.Handle("GET /api/chirps/{chirpID}/revisions", apiCfg.middlewareAuth(apiCfg.handlerGetChirpRevisions, auth.ScopeChirpsRead))
+	mux.Handle("GET /api/chirps/{chirpID}/thread", apiCfg.middlewareOptionalAuth(apiCfg.handlerGetChirpThread))
+	mux.Handle("POST /api/chirps/{chirpID}/like", apiCfg.middlewareAuth(apiCfg.handlerLikeChirp, auth.ScopeChirpsWrite))
+	mux.Handle("DELETE /api/chirps/{chirpID}/like", apiCfg.middlewareAuth(apiCfg.handlerUnlikeChirp, auth.ScopeChirpsWrite))
+	mux.Handle("POST /api/chirps/{chirpID}/rechirp", apiCfg.middlewareAuth(apiCfg.handlerRechirp, auth.ScopeChirpsWrite))
+	mux.Handle("GET /api/hashtags/{tag}/chirps", apiCfg.middlewareOptionalAuth(apiCfg.handlerGetHashtagChirps))
+	mux.Handle("GET /api/mentions", apiCfg.middlewareAuth(apiCfg.handlerGetMentions, auth.ScopeChirpsRead))
+
+	//media endpoints
+	mux.Handle("POST /api/media", apiCfg.middlewareAuth(apiCfg.handlerUploadMedia, auth.ScopeMediaWrite))
 
 	//user endpoints
 	mux.HandleFunc("POST /api/users", apiCfg.handlerCreateUser)
-	mux.HandleFunc("PUT /api/users", apiCfg.handlerUpdateUserPassword)
+	mux.Handle("PUT /api/users/password", apiCfg.middlewareAuth(apiCfg.handlerChangePassword, auth.ScopeUsersWrite))
+	mux.Handle("PUT /api/users/email", apiCfg.middlewareAuth(apiCfg.handlerChangeEmail, auth.ScopeUsersWrite))
 	mux.HandleFunc("POST /api/login", apiCfg.handlerLogin)
+	mux.HandleFunc("POST /api/login/2fa", apiCfg.handlerLoginTwoFactor)
+	mux.Handle("POST /api/users/2fa/enroll", apiCfg.middlewareAuth(apiCfg.handlerEnrollTwoFactor, auth.ScopeUsersWrite))
+	mux.Handle("POST /api/users/2fa/confirm", apiCfg.middlewareAuth(apiCfg.handlerConfirmTwoFactor, auth.ScopeUsersWrite))
+	if oidcProvider != nil {
+		mux.HandleFunc("GET /api/login/oidc", apiCfg.handlerStartOIDCLogin)
+		mux.HandleFunc("GET /api/login/oidc/callback", apiCfg.handlerOIDCCallback)
+	}
+	mux.HandleFunc("POST /api/users/verify", apiCfg.handlerVerifyEmail)
+	mux.HandleFunc("POST /api/users/verify/resend", apiCfg.handlerResendVerification)
+	mux.HandleFunc("POST /api/password/forgot", apiCfg.handlerForgotPassword)
+	mux.HandleFunc("POST /api/password/reset", apiCfg.handlerResetPassword)
+
+	//follow endpoints
+	mux.Handle("POST /api/users/{userID}/follow", apiCfg.middlewareAuth(apiCfg.handlerFollowUser, auth.ScopeUsersWrite))
+	mux.Handle("DELETE /api/users/{userID}/follow", apiCfg.middlewareAuth(apiCfg.handlerUnfollowUser, auth.ScopeUsersWrite))
+	mux.Handle("GET /api/timeline", apiCfg.middlewareAuth(apiCfg.handlerGetTimeline, auth.ScopeChirpsRead))
 
 	//token endpoints
 	mux.HandleFunc("POST /api/refresh", apiCfg.handlerRefreshToken)
 	mux.HandleFunc("POST /api/revoke", apiCfg.handlerRevokeToken)
+	mux.Handle("POST /api/tokens/personal", apiCfg.middlewareAuth(apiCfg.handlerCreatePersonalToken, auth.ScopeUsersWrite))
+	mux.Handle("GET /api/tokens/personal", apiCfg.middlewareAuth(apiCfg.handlerGetPersonalTokens, auth.ScopeUsersRead))
+	mux.Handle("DELETE /api/tokens/personal/{tokenID}", apiCfg.middlewareAuth(apiCfg.handlerRevokePersonalToken, auth.ScopeUsersWrite))
+
+	//session endpoints
+	mux.Handle("GET /api/sessions", apiCfg.middlewareAuth(apiCfg.handlerGetSessions, auth.ScopeUsersRead))
+	mux.Handle("DELETE /api/sessions", apiCfg.middlewareAuth(apiCfg.handlerRevokeAllSessions, auth.ScopeUsersWrite))
+	mux.Handle("DELETE /api/sessions/{sessionID}", apiCfg.middlewareAuth(apiCfg.handlerRevokeSession, auth.ScopeUsersWrite))
 
-	//webhook endpoint
-	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.handlerUpdateUserChirpyRed)
+	//webhook endpoints
+	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.handlerPolkaWebhook)
+	mux.Handle("POST /api/webhooks", apiCfg.middlewareWebhooks(apiCfg.handlerCreateWebhookSubscription))
+	mux.Handle("GET /api/webhooks", apiCfg.middlewareWebhooks(apiCfg.handlerGetWebhookSubscriptions))
+	mux.Handle("DELETE /api/webhooks/{subscriptionID}", apiCfg.middlewareWebhooks(apiCfg.handlerDeleteWebhookSubscription))
+	mux.Handle("GET /api/webhooks/{subscriptionID}/deliveries", apiCfg.middlewareWebhooks(apiCfg.handlerGetWebhookDeliveries))
 
 	//configure server
 	server := &http.Server{
@@ -85,3 +347,29 @@ func main() {
 	fmt.Printf("Serving on port :%s\n", port)
 	server.ListenAndServe()
 }
+
+// Read an integer env variable, using fallback when it isn't set
+func envInt64(name string, fallback int64) int64 {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		log.Fatalf("Invalid %s: %s", name, err)
+	}
+	return n
+}
+
+// Read a duration env variable like "90m", using fallback when it isn't set
+func envDuration(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("Invalid %s: %s", name, err)
+	}
+	return d
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skarsden/Chirp/internal/auth"
+	"github.com/skarsden/Chirp/internal/database"
+)
+
+type contextKey int
+
+const authUserKey contextKey = iota
+
+// Signed in user for the current request, set by the auth middleware
+type authUser struct {
+	ID     uuid.UUID
+	Claims *auth.Claims
+	// set when the request was made with a personal token rather than a JWT
+	PersonalTokenID uuid.NullUUID
+}
+
+// Personal token lookups for auth.Authenticate
+type personalTokenStore struct {
+	queries *database.Queries
+}
+
+func (s personalTokenStore) PersonalTokenByHash(ctx context.Context, tokenHash string) (auth.PersonalTokenRecord, error) {
+	token, err := s.queries.GetPersonalTokenByHash(ctx, tokenHash)
+	if err != nil {
+		return auth.PersonalTokenRecord{}, err
+	}
+	return auth.PersonalTokenRecord{
+		ID:        token.ID,
+		UserID:    token.UserID,
+		Scope:     token.Scope,
+		ExpiresAt: token.ExpiresAt,
+		RevokedAt: token.RevokedAt,
+	}, nil
+}
+
+// Authenticate a bearer token, either a JWT or a personal token
+func (cfg *apiConfig) authenticate(ctx context.Context, token string) (authUser, error) {
+	identity, err := auth.Authenticate(ctx, token, cfg.jwtKeys, personalTokenStore{queries: cfg.queries}, time.Now())
+	if err != nil {
+		return authUser{}, err
+	}
+
+	if identity.PersonalTokenID.Valid {
+		//last_used_at only moves once a minute, so busy bots don't write on every request
+		err = cfg.queries.TouchPersonalToken(ctx, identity.PersonalTokenID.UUID)
+		if err != nil {
+			log.Printf("Couldn't update personal token last use: %s\n", err)
+		}
+	}
+	return authUser{ID: identity.UserID, Claims: identity.Claims, PersonalTokenID: identity.PersonalTokenID}, nil
+}
+
+// Require a valid bearer access token granted every one of scopes
+func (cfg *apiConfig) middlewareAuth(next http.HandlerFunc, scopes ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			log.Printf("Couldn't find access token: %s\n", err)
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			respondWithError(w, http.StatusUnauthorized, "missing_access_token", "Request is missing a bearer access token")
+			return
+		}
+
+		user, err := cfg.authenticate(r.Context(), token)
+		if errors.Is(err, auth.ErrInvalidToken) {
+			log.Printf("Couldn't validate access token: %s\n", err)
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			respondWithError(w, http.StatusUnauthorized, "invalid_access_token", "Access token is invalid or expired")
+			return
+		}
+		if err != nil {
+			log.Printf("Couldn't look up access token: %s\n", err)
+			respondWithInternalError(w)
+			return
+		}
+
+		if !user.Claims.HasScopes(scopes...) {
+			log.Printf("Access token for %s is missing scopes %v\n", user.ID, scopes)
+			w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+strings.Join(scopes, " ")+`"`)
+			respondWithProblem(w, newProblem(http.StatusForbidden, "insufficient_scope", "Access token is missing a required scope").
+				with("required_scopes", scopes))
+			return
+		}
+
+		next.ServeHTTP(w, withAuthUser(r, user))
+	})
+}
+
+// Add the user to the request when a valid bearer token is sent, for public endpoints
+// that show more to signed in users. A missing or bad token is treated as anonymous.
+func (cfg *apiConfig) middlewareOptionalAuth(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		user, err := cfg.authenticate(r.Context(), token)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, withAuthUser(r, user))
+	})
+}
+
+// Require the admin API key, admin endpoints are turned off while ADMIN_API_KEY isn't set
+func (cfg *apiConfig) middlewareAdmin(next http.HandlerFunc) http.Handler {
+	return requireAPIKey(cfg.adminKey, "admin", next)
+}
+
+// Require the webhooks API key for managing subscriptions. It's separate from the admin key, which
+// can also replay Polka events. Subscriptions are turned off while WEBHOOKS_API_KEY isn't set
+func (cfg *apiConfig) middlewareWebhooks(next http.HandlerFunc) http.Handler {
+	return requireAPIKey(cfg.webhooksKey, "webhooks", next)
+}
+
+// Require an ApiKey header matching apiKey, the endpoints named by area are turned off while it's empty
+func requireAPIKey(apiKey, area string, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if apiKey == "" {
+			log.Printf("%s endpoint called without its API key set\n", area)
+			respondWithError(w, http.StatusForbidden, area+"_disabled", "These endpoints are turned off")
+			return
+		}
+
+		key, err := auth.GetApiKey(r.Header)
+		if err != nil {
+			log.Printf("Couldn't get api key: %s\n", err)
+			respondWithError(w, http.StatusUnauthorized, "missing_api_key", "Request is missing an ApiKey authorization header")
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(key), []byte(apiKey)) != 1 {
+			log.Printf("Invalid %s api key\n", area)
+			respondWithError(w, http.StatusUnauthorized, "invalid_api_key", "API key is invalid")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func withAuthUser(r *http.Request, user authUser) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), authUserKey, user))
+}
+
+// Get the signed in user set by the auth middleware
+func authUserFromContext(ctx context.Context) (authUser, bool) {
+	user, ok := ctx.Value(authUserKey).(authUser)
+	return user, ok
+}
+
+// ID of the signed in user, only for routes behind middlewareAuth
+func requestUserID(r *http.Request) uuid.UUID {
+	user, ok := authUserFromContext(r.Context())
+	if !ok {
+		//a route is missing the middleware, fail loudly rather than act as nobody
+		panic("requestUserID called on a route without auth middleware")
+	}
+	return user.ID
+}
+
+// ID of the signed in user, if any, for routes behind middlewareOptionalAuth
+func optionalUserID(r *http.Request) uuid.NullUUID {
+	user, ok := authUserFromContext(r.Context())
+	if !ok {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: user.ID, Valid: true}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skarsden/Chirp/internal/database"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// Page of chirps returned by list endpoints
+type ChirpsPage struct {
+	Chirps     []Chirp `json:"chirps"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+}
+
+// Pointers to the chirps on the page, for filling in per-viewer fields
+func (page ChirpsPage) chirpRefs() []*Chirp {
+	refs := make([]*Chirp, 0, len(page.Chirps))
+	for i := range page.Chirps {
+		refs = append(refs, &page.Chirps[i])
+	}
+	return refs
+}
+
+// Position of the last row on a page, used for keyset pagination
+type pageCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// Encode cursor as an opaque url-safe string
+func encodeCursor(createdAt time.Time, id uuid.UUID) string {
+	raw := createdAt.UTC().Format(time.RFC3339Nano) + "|" + id.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// Decode cursor produced by encodeCursor
+func decodeCursor(cursor string) (pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return pageCursor{}, errors.New("malformed cursor")
+	}
+	createdAtString, idString, found := strings.Cut(string(data), "|")
+	if !found {
+		return pageCursor{}, errors.New("malformed cursor")
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, createdAtString)
+	if err != nil {
+		return pageCursor{}, errors.New("malformed cursor timestamp")
+	}
+	id, err := uuid.Parse(idString)
+	if err != nil {
+		return pageCursor{}, errors.New("malformed cursor id")
+	}
+	return pageCursor{CreatedAt: createdAt, ID: id}, nil
+}
+
+// Parse cursor query param into the nullable params taken by the list queries
+func parseCursorParam(cursorString string) (sql.NullTime, uuid.NullUUID, error) {
+	if cursorString == "" {
+		return sql.NullTime{}, uuid.NullUUID{}, nil
+	}
+	cursor, err := decodeCursor(cursorString)
+	if err != nil {
+		return sql.NullTime{}, uuid.NullUUID{}, err
+	}
+	return sql.NullTime{Time: cursor.CreatedAt, Valid: true}, uuid.NullUUID{UUID: cursor.ID, Valid: true}, nil
+}
+
+// Build page from rows fetched with limit+1, the extra row only signals a next page
+func newChirpsPage(dbChirps []database.Chirp, limit int) ChirpsPage {
+	page := ChirpsPage{Chirps: []Chirp{}}
+	if len(dbChirps) > limit {
+		dbChirps = dbChirps[:limit]
+		last := dbChirps[len(dbChirps)-1]
+		page.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+	for _, dbChirp := range dbChirps {
+		page.Chirps = append(page.Chirps, chirpFromDB(dbChirp))
+	}
+	return page
+}
+
+// Parse limit query param, falling back to the default page size
+func parsePageLimit(limitString string) (int, error) {
+	if limitString == "" {
+		return defaultPageLimit, nil
+	}
+	limit, err := strconv.Atoi(limitString)
+	if err != nil || limit < 1 {
+		return 0, errors.New("limit must be a positive integer")
+	}
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+	return limit, nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// Problem is an RFC 7807 problem details response. Code is a stable,
+// machine-readable identifier clients can switch on, Detail is for humans.
+type Problem struct {
+	Type   string
+	Title  string
+	Status int
+	Code   string
+	Detail string
+	Errors []FieldError
+	// Extra members written alongside the standard ones, e.g. "limit"
+	Extensions map[string]any
+}
+
+// Validation error for a single request field or query param
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Limit   int64  `json:"limit,omitempty"`
+}
+
+// Create problem for status, the title is the standard status text
+func newProblem(status int, code, detail string) Problem {
+	return Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Code:   code,
+		Detail: detail,
+	}
+}
+
+// Add field level validation errors
+func (p Problem) withFieldErrors(errs ...FieldError) Problem {
+	p.Errors = append(p.Errors, errs...)
+	return p
+}
+
+// Add an extension member
+func (p Problem) with(key string, value any) Problem {
+	extensions := map[string]any{}
+	for k, v := range p.Extensions {
+		extensions[k] = v
+	}
+	extensions[key] = value
+	p.Extensions = extensions
+	return p
+}
+
+// Extensions are flattened into the top level object as RFC 7807 expects
+func (p Problem) MarshalJSON() ([]byte, error) {
+	members := map[string]any{}
+	for k, v := range p.Extensions {
+		members[k] = v
+	}
+	members["type"] = p.Type
+	members["title"] = p.Title
+	members["status"] = p.Status
+	members["code"] = p.Code
+	if p.Detail != "" {
+		members["detail"] = p.Detail
+	}
+	if len(p.Errors) > 0 {
+		members["errors"] = p.Errors
+	}
+	return json.Marshal(members)
+}
+
+// Write problem as application/problem+json
+func respondWithProblem(w http.ResponseWriter, p Problem) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		log.Printf("Error marshalling problem: %s\n", err)
+		respondWithInternalError(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	w.Write(data)
+}
+
+// Write problem with just a code and message
+func respondWithError(w http.ResponseWriter, status int, code, detail string) {
+	respondWithProblem(w, newProblem(status, code, detail))
+}
+
+// Write problem for a single invalid field or query param
+func respondWithFieldError(w http.ResponseWriter, status int, code, field, detail string) {
+	respondWithProblem(w, newProblem(status, code, detail).withFieldErrors(FieldError{
+		Field:   field,
+		Code:    "invalid",
+		Message: detail,
+	}))
+}
+
+// Write problem for something that went wrong on our side, details stay in the log
+func respondWithInternalError(w http.ResponseWriter) {
+	respondWithError(w, http.StatusInternalServerError, "internal_error", "Something went wrong, please try again later")
+}
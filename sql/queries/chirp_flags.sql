@@ -0,0 +1,9 @@
+-- name: CreateChirpFlag :one
+INSERT INTO chirp_flags (id, created_at, chirp_id, reason)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2
+)
+RETURNING *;
\ No newline at end of file
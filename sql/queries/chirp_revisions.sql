@@ -0,0 +1,18 @@
+-- name: CreateChirpRevision :one
+INSERT INTO chirp_revisions (id, created_at, replaced_at, chirp_id, body)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    NOW(),
+    $2,
+    $3
+)
+RETURNING *;
+
+-- name: GetChirpRevisions :many
+SELECT * FROM chirp_revisions
+WHERE chirp_id = $1
+ORDER BY replaced_at ASC;
+
+-- name: DeleteChirpRevisions :exec
+DELETE FROM chirp_revisions WHERE chirp_id = $1;
\ No newline at end of file
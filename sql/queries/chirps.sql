@@ -1,22 +1,163 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, in_reply_to)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3
 )
 RETURNING *;
 
 -- name: DeleteChirps :exec
 DELETE FROM chirps;
 
--- name: GetChirps :many
-SELECT * FROM chirps ORDER BY created_at ASC;
+-- name: ListChirpsAsc :many
+SELECT * FROM chirps
+WHERE deleted_at IS NULL
+AND (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id'))
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR created_at > sqlc.narg('cursor_created_at')
+    OR (created_at = sqlc.narg('cursor_created_at') AND id > sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg('limit');
+
+-- name: ListChirpsDesc :many
+SELECT * FROM chirps
+WHERE deleted_at IS NULL
+AND (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id'))
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR created_at < sqlc.narg('cursor_created_at')
+    OR (created_at = sqlc.narg('cursor_created_at') AND id < sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
 
 -- name: GetChirp :one
 SELECT * FROM chirps WHERE id = $1;
 
 -- name: DeleteChirpById :exec
-DELETE FROM chirps WHERE id = $1;
\ No newline at end of file
+DELETE FROM chirps WHERE id = $1;
+
+-- name: GetChirpForUpdate :one
+SELECT * FROM chirps WHERE id = $1 FOR UPDATE;
+
+-- name: UpdateChirpBody :one
+UPDATE chirps SET body = $1, updated_at = NOW()
+WHERE id = $2
+RETURNING *;
+
+-- name: ListTimelineChirps :many
+SELECT chirps.* FROM chirps
+JOIN follows ON follows.followee_id = chirps.user_id
+WHERE follows.follower_id = sqlc.arg('follower_id')
+AND chirps.deleted_at IS NULL
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR chirps.created_at < sqlc.narg('cursor_created_at')
+    OR (chirps.created_at = sqlc.narg('cursor_created_at') AND chirps.id < sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('limit');
+
+
+-- name: TombstoneChirp :exec
+UPDATE chirps SET body = '', deleted_at = NOW(), updated_at = NOW()
+WHERE id = $1;
+
+-- name: ChirpHasReplies :one
+SELECT EXISTS (SELECT 1 FROM chirps WHERE in_reply_to = $1) AS has_replies;
+
+-- name: GetChirpAncestors :many
+WITH RECURSIVE ancestors AS (
+    SELECT parent.id, parent.in_reply_to, 1 AS depth
+    FROM chirps child
+    JOIN chirps parent ON parent.id = child.in_reply_to
+    WHERE child.id = $1
+    UNION ALL
+    SELECT chirps.id, chirps.in_reply_to, ancestors.depth + 1
+    FROM chirps
+    JOIN ancestors ON chirps.id = ancestors.in_reply_to
+)
+SELECT chirps.* FROM chirps
+JOIN ancestors ON chirps.id = ancestors.id
+ORDER BY ancestors.depth DESC;
+
+-- name: GetChirpReplies :many
+WITH RECURSIVE replies AS (
+    SELECT id, 1 AS depth FROM chirps WHERE in_reply_to = sqlc.arg('chirp_id')
+    UNION ALL
+    SELECT chirps.id, replies.depth + 1
+    FROM chirps
+    JOIN replies ON chirps.in_reply_to = replies.id
+    WHERE replies.depth < sqlc.arg('max_depth')
+)
+SELECT chirps.* FROM chirps
+JOIN replies ON chirps.id = replies.id
+ORDER BY chirps.created_at ASC, chirps.id ASC;
+
+-- name: AdjustChirpLikeCount :one
+UPDATE chirps SET like_count = like_count + sqlc.arg('delta')
+WHERE id = sqlc.arg('id')
+RETURNING *;
+
+-- name: IncrementChirpRechirpCount :one
+UPDATE chirps SET rechirp_count = rechirp_count + 1
+WHERE id = $1
+RETURNING *;
+
+-- name: SearchChirps :many
+SELECT chirps.*, ts_rank(search_vector, to_tsquery('english', sqlc.arg('query'))) AS rank
+FROM chirps
+WHERE search_vector @@ to_tsquery('english', sqlc.arg('query'))
+AND deleted_at IS NULL
+AND (sqlc.narg('author_id')::uuid IS NULL OR user_id = sqlc.narg('author_id'))
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR (sqlc.arg('sort')::text = 'asc' AND (created_at, id) > (sqlc.narg('cursor_created_at'), sqlc.narg('cursor_id')::uuid))
+    OR (sqlc.arg('sort')::text = 'desc' AND (created_at, id) < (sqlc.narg('cursor_created_at'), sqlc.narg('cursor_id')::uuid))
+    OR (
+        sqlc.arg('sort')::text = ''
+        AND (ts_rank(search_vector, to_tsquery('english', sqlc.arg('query'))), created_at, id)
+            < (sqlc.narg('cursor_rank')::real, sqlc.narg('cursor_created_at'), sqlc.narg('cursor_id')::uuid)
+    )
+)
+ORDER BY
+    CASE WHEN sqlc.arg('sort')::text = 'asc' THEN created_at END ASC,
+    CASE WHEN sqlc.arg('sort')::text = 'asc' THEN id END ASC,
+    CASE WHEN sqlc.arg('sort')::text = 'desc' THEN created_at END DESC,
+    CASE WHEN sqlc.arg('sort')::text = 'desc' THEN id END DESC,
+    rank DESC,
+    created_at DESC,
+    id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: ListHashtagChirps :many
+SELECT chirps.* FROM chirps
+JOIN chirp_hashtags ON chirp_hashtags.chirp_id = chirps.id
+WHERE chirp_hashtags.tag = sqlc.arg('tag')
+AND chirps.deleted_at IS NULL
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR chirps.created_at < sqlc.narg('cursor_created_at')
+    OR (chirps.created_at = sqlc.narg('cursor_created_at') AND chirps.id < sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('limit');
+
+-- name: ListMentionChirps :many
+SELECT chirps.* FROM chirps
+JOIN chirp_mentions ON chirp_mentions.chirp_id = chirps.id
+WHERE chirp_mentions.user_id = sqlc.arg('user_id')
+AND chirps.deleted_at IS NULL
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR chirps.created_at < sqlc.narg('cursor_created_at')
+    OR (chirps.created_at = sqlc.narg('cursor_created_at') AND chirps.id < sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg('limit');
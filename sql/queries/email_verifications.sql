@@ -0,0 +1,18 @@
+-- name: CreateEmailVerification :exec
+INSERT INTO email_verifications (token_hash, created_at, user_id, email, expires_at)
+VALUES (
+    $1,
+    NOW(),
+    $2,
+    $3,
+    $4
+);
+
+-- name: GetEmailVerificationForUpdate :one
+SELECT * FROM email_verifications
+WHERE token_hash = $1
+FOR UPDATE;
+
+-- name: UseEmailVerification :exec
+UPDATE email_verifications SET used_at = NOW()
+WHERE token_hash = $1;
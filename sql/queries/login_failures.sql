@@ -0,0 +1,30 @@
+-- name: GetLoginFailure :one
+SELECT * FROM login_failures
+WHERE key = $1;
+
+-- name: AddLoginFailure :one
+INSERT INTO login_failures (key, failures, last_failure_at)
+VALUES (
+    sqlc.arg('key'),
+    1,
+    sqlc.arg('failed_at')
+)
+ON CONFLICT (key) DO UPDATE SET
+    failures = CASE
+        WHEN login_failures.last_failure_at < sqlc.arg('window_start') THEN 1
+        ELSE login_failures.failures + 1
+    END,
+    last_failure_at = EXCLUDED.last_failure_at
+WHERE login_failures.failures = sqlc.arg('seen_failures')
+    AND login_failures.last_failure_at = sqlc.arg('seen_last_failure_at')
+RETURNING *;
+
+-- name: RefundLoginFailure :exec
+UPDATE login_failures SET failures = failures - 1
+WHERE key = $1 AND failures > 0;
+
+-- name: DeleteLoginFailure :exec
+DELETE FROM login_failures WHERE key = $1;
+
+-- name: DeleteLoginFailuresBefore :exec
+DELETE FROM login_failures WHERE last_failure_at < $1;
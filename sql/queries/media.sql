@@ -0,0 +1,41 @@
+-- name: CreateMedia :one
+INSERT INTO media (id, created_at, user_id, blob_key, content_type, size_bytes, width, height)
+VALUES (
+    $1,
+    NOW(),
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7
+)
+RETURNING *;
+
+-- name: GetVisibleMedia :one
+SELECT media.* FROM media
+JOIN chirps ON chirps.id = media.chirp_id
+WHERE media.id = $1 AND chirps.deleted_at IS NULL;
+
+-- name: AttachMediaToChirp :execrows
+UPDATE media SET chirp_id = sqlc.arg('chirp_id')
+WHERE id = ANY(sqlc.arg('media_ids')::uuid[])
+AND user_id = sqlc.arg('user_id')
+AND chirp_id IS NULL;
+
+-- name: GetChirpsMedia :many
+SELECT * FROM media
+WHERE chirp_id = ANY(sqlc.arg('chirp_ids')::uuid[])
+ORDER BY created_at ASC;
+
+-- name: DeleteChirpMedia :many
+DELETE FROM media WHERE chirp_id = $1
+RETURNING blob_key;
+
+-- name: DeleteUnattachedMedia :many
+DELETE FROM media WHERE chirp_id IS NULL AND created_at < $1
+RETURNING blob_key;
+
+-- name: DeleteAllMedia :many
+DELETE FROM media
+RETURNING blob_key;
\ No newline at end of file
@@ -0,0 +1,32 @@
+-- name: CreateOIDCLogin :exec
+INSERT INTO oidc_logins (state_hash, code_verifier, nonce, created_at, expires_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    NOW(),
+    $4
+);
+
+-- name: GetOIDCLoginForUpdate :one
+SELECT * FROM oidc_logins
+WHERE state_hash = $1
+FOR UPDATE;
+
+-- name: UseOIDCLogin :exec
+UPDATE oidc_logins SET used_at = NOW()
+WHERE state_hash = $1;
+
+-- name: GetUserIdentity :one
+SELECT * FROM user_identities
+WHERE issuer = $1 AND subject = $2;
+
+-- name: CreateUserIdentity :exec
+INSERT INTO user_identities (issuer, subject, user_id, email, created_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    NOW()
+);
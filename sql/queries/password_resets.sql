@@ -0,0 +1,17 @@
+-- name: CreatePasswordReset :exec
+INSERT INTO password_resets (token_hash, created_at, user_id, expires_at)
+VALUES (
+    $1,
+    NOW(),
+    $2,
+    $3
+);
+
+-- name: GetPasswordResetForUpdate :one
+SELECT * FROM password_resets
+WHERE token_hash = $1
+FOR UPDATE;
+
+-- name: UseUserPasswordResets :exec
+UPDATE password_resets SET used_at = NOW()
+WHERE user_id = $1 AND used_at IS NULL;
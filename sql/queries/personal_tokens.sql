@@ -0,0 +1,33 @@
+-- name: CreatePersonalToken :one
+INSERT INTO personal_tokens (id, user_id, name, token_hash, scope, created_at, expires_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    NOW(),
+    $5
+)
+RETURNING *;
+
+-- name: GetPersonalTokenByHash :one
+SELECT * FROM personal_tokens
+WHERE token_hash = $1;
+
+-- name: ListPersonalTokens :many
+SELECT * FROM personal_tokens
+WHERE user_id = $1 AND revoked_at IS NULL
+ORDER BY created_at DESC;
+
+-- name: TouchPersonalToken :exec
+UPDATE personal_tokens SET last_used_at = NOW()
+WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute');
+
+-- name: RevokePersonalToken :execrows
+UPDATE personal_tokens SET revoked_at = NOW()
+WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;
+
+-- name: RevokeAllPersonalTokens :execrows
+UPDATE personal_tokens SET revoked_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
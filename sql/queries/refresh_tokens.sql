@@ -1,20 +1,60 @@
 -- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at)
+INSERT INTO refresh_tokens (token_hash, created_at, updated_at, user_id, expires_at, family_id, user_agent, ip_address)
 VALUES (
     $1,
     NOW(),
     NOW(),
     $2,
-    $3
+    $3,
+    $4,
+    $5,
+    $6
 ) 
 RETURNING *;
 
 -- name: RevokeRefreshToken :one
 UPDATE refresh_tokens SET revoked_at = NOW(), updated_at = NOW()
-WHERE token = $1
+WHERE token_hash = $1
 RETURNING *;
 
 -- name: GetUserFromRefreshToken :one
 SELECT users.* FROM users
 JOIN refresh_tokens ON users.id = refresh_tokens.user_id
-WHERE refresh_tokens.token = $1 AND revoked_at IS NULL AND expires_at > NOW();
\ No newline at end of file
+WHERE refresh_tokens.token_hash = $1 AND revoked_at IS NULL AND replaced_at IS NULL AND expires_at > NOW();
+
+-- name: GetRefreshTokenForUpdate :one
+SELECT * FROM refresh_tokens
+WHERE token_hash = $1
+FOR UPDATE;
+
+-- name: ReplaceRefreshToken :execrows
+UPDATE refresh_tokens SET replaced_at = NOW(), replaced_by = $2, updated_at = NOW()
+WHERE token_hash = $1 AND replaced_at IS NULL AND revoked_at IS NULL;
+
+-- name: RevokeRefreshTokenFamily :execrows
+UPDATE refresh_tokens SET revoked_at = NOW(), updated_at = NOW()
+WHERE family_id = $1 AND revoked_at IS NULL;
+
+-- name: ListSessions :many
+SELECT
+    latest.family_id,
+    (SELECT MIN(family.created_at) FROM refresh_tokens family WHERE family.family_id = latest.family_id)::timestamp AS created_at,
+    latest.created_at AS last_used_at,
+    latest.user_agent,
+    latest.ip_address,
+    latest.expires_at
+FROM refresh_tokens latest
+WHERE latest.user_id = $1 AND latest.revoked_at IS NULL AND latest.replaced_at IS NULL AND latest.expires_at > NOW()
+ORDER BY latest.created_at DESC;
+
+-- name: RevokeSession :execrows
+UPDATE refresh_tokens SET revoked_at = NOW(), updated_at = NOW()
+WHERE family_id = $1 AND user_id = $2 AND revoked_at IS NULL;
+
+-- name: RevokeAllSessions :execrows
+UPDATE refresh_tokens SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
+
+-- name: RevokeOtherSessions :execrows
+UPDATE refresh_tokens SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1 AND family_id <> $2 AND revoked_at IS NULL;
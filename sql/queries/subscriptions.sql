@@ -0,0 +1,33 @@
+-- name: ActivateSubscription :one
+INSERT INTO subscriptions (user_id, created_at, updated_at, plan, status, current_period_end, last_event_at)
+VALUES (
+    $1,
+    NOW(),
+    NOW(),
+    $2,
+    'active',
+    $3,
+    $4
+)
+ON CONFLICT (user_id) DO UPDATE SET
+    updated_at = NOW(),
+    plan = EXCLUDED.plan,
+    status = 'active',
+    current_period_end = EXCLUDED.current_period_end,
+    last_event_at = EXCLUDED.last_event_at
+RETURNING *;
+
+-- name: SetSubscriptionStatus :execrows
+UPDATE subscriptions SET status = $2, last_event_at = $3, updated_at = NOW()
+WHERE user_id = $1;
+
+-- name: GetSubscriptionForUpdate :one
+SELECT * FROM subscriptions
+WHERE user_id = $1
+FOR UPDATE;
+
+-- name: IsUserChirpyRed :one
+SELECT EXISTS (
+    SELECT 1 FROM subscriptions
+    WHERE user_id = $1 AND status = 'active' AND (current_period_end IS NULL OR current_period_end > NOW())
+);
@@ -0,0 +1,71 @@
+-- name: UpsertUserTOTP :exec
+INSERT INTO user_totp (user_id, created_at, encrypted_secret)
+VALUES (
+    $1,
+    NOW(),
+    $2
+)
+ON CONFLICT (user_id) DO UPDATE SET
+    created_at = EXCLUDED.created_at,
+    encrypted_secret = EXCLUDED.encrypted_secret,
+    confirmed_at = NULL,
+    last_used_step = 0;
+
+-- name: GetUserTOTP :one
+SELECT * FROM user_totp
+WHERE user_id = $1;
+
+-- name: GetUserTOTPForUpdate :one
+SELECT * FROM user_totp
+WHERE user_id = $1
+FOR UPDATE;
+
+-- name: ConfirmUserTOTP :exec
+UPDATE user_totp SET confirmed_at = NOW(), last_used_step = $2
+WHERE user_id = $1;
+
+-- name: SetTOTPLastUsedStep :exec
+UPDATE user_totp SET last_used_step = $2
+WHERE user_id = $1;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes WHERE user_id = $1;
+
+-- name: CreateRecoveryCode :exec
+INSERT INTO recovery_codes (id, user_id, code_hash, created_at)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    NOW()
+);
+
+-- name: GetUnusedRecoveryCodes :many
+SELECT * FROM recovery_codes
+WHERE user_id = $1 AND used_at IS NULL;
+
+-- name: UseRecoveryCode :execrows
+UPDATE recovery_codes SET used_at = NOW()
+WHERE id = $1 AND used_at IS NULL;
+
+-- name: CreateLoginChallenge :exec
+INSERT INTO login_challenges (token_hash, user_id, created_at, expires_at)
+VALUES (
+    $1,
+    $2,
+    NOW(),
+    $3
+);
+
+-- name: GetLoginChallengeForUpdate :one
+SELECT * FROM login_challenges
+WHERE token_hash = $1
+FOR UPDATE;
+
+-- name: FailLoginChallenge :exec
+UPDATE login_challenges SET attempts = attempts + 1
+WHERE token_hash = $1;
+
+-- name: UseLoginChallenge :exec
+UPDATE login_challenges SET used_at = NOW()
+WHERE token_hash = $1;
@@ -15,12 +15,17 @@ DELETE FROM users;
 -- name: GetUserByEmail :one
 SELECT * FROM users WHERE $1 = email;
 
--- name: UpdateUserPassword :one
-UPDATE users SET email = $1, hashed_password = $2
-WHERE id = $3
-RETURNING *;
+-- name: GetUserByID :one
+SELECT * FROM users WHERE id = $1;
+
+-- name: GetUsersByEmails :many
+SELECT * FROM users WHERE lower(email) = ANY(sqlc.arg('emails')::text[]);
 
--- name: UpdateUserChirpyRed :one
-UPDATE users SET is_chirpy_red = true
+-- name: VerifyUserEmail :one
+UPDATE users SET email = $2, email_verified_at = NOW(), updated_at = NOW()
 WHERE id = $1
-RETURNING *;
\ No newline at end of file
+RETURNING *;
+
+-- name: SetUserHashedPassword :exec
+UPDATE users SET hashed_password = $2, updated_at = NOW()
+WHERE id = $1;
@@ -0,0 +1,38 @@
+-- name: EnqueueWebhookDeliveries :execrows
+INSERT INTO webhook_deliveries (id, subscription_id, event_type, payload, status, next_attempt_at, created_at)
+SELECT gen_random_uuid(), id, sqlc.arg('event_type'), sqlc.arg('payload'), 'pending', NOW(), NOW()
+FROM webhook_subscriptions
+WHERE events = '*' OR sqlc.arg('event_type')::text = ANY(string_to_array(events, ' '));
+
+-- name: ClaimWebhookDeliveries :many
+UPDATE webhook_deliveries SET next_attempt_at = sqlc.arg('lease_until')
+FROM webhook_subscriptions
+WHERE webhook_deliveries.id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= NOW()
+    ORDER BY next_attempt_at
+    LIMIT sqlc.arg('limit')
+    FOR UPDATE SKIP LOCKED
+)
+AND webhook_subscriptions.id = webhook_deliveries.subscription_id
+RETURNING webhook_deliveries.id, webhook_deliveries.attempts, webhook_deliveries.payload, webhook_subscriptions.url, webhook_subscriptions.encrypted_secret;
+
+-- name: RecordWebhookDeliverySuccess :exec
+UPDATE webhook_deliveries SET status = 'succeeded', attempts = attempts + 1, last_status_code = $2, last_error = NULL, delivered_at = NOW()
+WHERE id = $1;
+
+-- name: RecordWebhookDeliveryFailure :exec
+UPDATE webhook_deliveries SET status = $2, attempts = attempts + 1, next_attempt_at = $3, last_status_code = $4, last_error = $5
+WHERE id = $1;
+
+-- name: ListWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE subscription_id = sqlc.arg('subscription_id')
+AND (sqlc.narg('status')::text IS NULL OR status = sqlc.narg('status'))
+AND (
+    sqlc.narg('cursor_created_at')::timestamp IS NULL
+    OR created_at < sqlc.narg('cursor_created_at')
+    OR (created_at = sqlc.narg('cursor_created_at') AND id < sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg('limit');
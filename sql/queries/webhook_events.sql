@@ -0,0 +1,42 @@
+-- name: CreateWebhookEvent :one
+INSERT INTO webhook_events (id, provider, event_id, event_type, payload, received_at, occurred_at, status)
+VALUES (
+    gen_random_uuid(),
+    $1,
+    $2,
+    $3,
+    $4,
+    NOW(),
+    $5,
+    'pending'
+)
+ON CONFLICT (provider, event_id) DO NOTHING
+RETURNING *;
+
+-- name: GetWebhookEventByEventID :one
+SELECT * FROM webhook_events
+WHERE provider = $1 AND event_id = $2;
+
+-- name: GetWebhookEvent :one
+SELECT * FROM webhook_events
+WHERE id = $1;
+
+-- name: GetWebhookEventForUpdate :one
+SELECT * FROM webhook_events
+WHERE id = $1
+FOR UPDATE;
+
+-- name: FinishWebhookEvent :exec
+UPDATE webhook_events SET status = $2, attempts = attempts + 1, last_error = $3, processed_at = NOW()
+WHERE id = $1;
+
+-- name: ListWebhookEvents :many
+SELECT * FROM webhook_events
+WHERE (sqlc.narg('status')::text IS NULL OR status = sqlc.narg('status'))
+AND (
+    sqlc.narg('cursor_received_at')::timestamp IS NULL
+    OR received_at < sqlc.narg('cursor_received_at')
+    OR (received_at = sqlc.narg('cursor_received_at') AND id < sqlc.narg('cursor_id')::uuid)
+)
+ORDER BY received_at DESC, id DESC
+LIMIT sqlc.arg('limit');
@@ -0,0 +1,10 @@
+-- +goose Up
+CREATE TABLE chirp_flags (
+    id          UUID PRIMARY KEY,
+    created_at  TIMESTAMP NOT NULL,
+    chirp_id    UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    reason      TEXT NOT NULL
+);
+
+-- +goose Down
+DROP TABLE chirp_flags;
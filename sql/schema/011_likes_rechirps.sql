@@ -0,0 +1,32 @@
+-- +goose Up
+CREATE TABLE chirp_likes (
+    chirp_id    UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    user_id     UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at  TIMESTAMP NOT NULL,
+    PRIMARY KEY (chirp_id, user_id)
+);
+
+CREATE TABLE rechirps (
+    chirp_id    UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    user_id     UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at  TIMESTAMP NOT NULL,
+    PRIMARY KEY (chirp_id, user_id)
+);
+
+ALTER TABLE chirps
+ADD COLUMN like_count INTEGER NOT NULL
+DEFAULT 0;
+
+ALTER TABLE chirps
+ADD COLUMN rechirp_count INTEGER NOT NULL
+DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE chirps
+DROP COLUMN rechirp_count;
+
+ALTER TABLE chirps
+DROP COLUMN like_count;
+
+DROP TABLE rechirps;
+DROP TABLE chirp_likes;
@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE media (
+    id           UUID PRIMARY KEY,
+    created_at   TIMESTAMP NOT NULL,
+    user_id      UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id     UUID REFERENCES chirps(id) ON DELETE CASCADE,
+    blob_key     TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size_bytes   BIGINT NOT NULL,
+    width        INTEGER NOT NULL,
+    height       INTEGER NOT NULL
+);
+
+CREATE INDEX media_chirp_id_idx ON media (chirp_id);
+
+-- +goose Down
+DROP TABLE media;
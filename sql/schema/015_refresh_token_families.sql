@@ -0,0 +1,28 @@
+-- +goose Up
+-- every login starts a family, each refresh replaces the family's current token
+ALTER TABLE refresh_tokens
+ADD COLUMN family_id UUID NOT NULL
+DEFAULT gen_random_uuid();
+
+ALTER TABLE refresh_tokens
+ALTER COLUMN family_id DROP DEFAULT;
+
+ALTER TABLE refresh_tokens
+ADD COLUMN replaced_at TIMESTAMP;
+
+ALTER TABLE refresh_tokens
+ADD COLUMN replaced_by TEXT REFERENCES refresh_tokens(token) ON DELETE SET NULL;
+
+CREATE INDEX refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+
+-- +goose Down
+DROP INDEX refresh_tokens_family_id_idx;
+
+ALTER TABLE refresh_tokens
+DROP COLUMN replaced_by;
+
+ALTER TABLE refresh_tokens
+DROP COLUMN replaced_at;
+
+ALTER TABLE refresh_tokens
+DROP COLUMN family_id;
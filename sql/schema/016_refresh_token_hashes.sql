@@ -0,0 +1,23 @@
+-- +goose Up
+-- only a SHA-256 digest of each refresh token is kept, existing tokens are
+-- rehashed in place so sessions stay signed in
+ALTER TABLE refresh_tokens
+DROP CONSTRAINT refresh_tokens_replaced_by_fkey;
+
+UPDATE refresh_tokens SET
+    token = encode(sha256(convert_to(token, 'UTF8')), 'hex'),
+    replaced_by = encode(sha256(convert_to(replaced_by, 'UTF8')), 'hex');
+
+ALTER TABLE refresh_tokens
+RENAME COLUMN token TO token_hash;
+
+ALTER TABLE refresh_tokens
+ADD CONSTRAINT refresh_tokens_replaced_by_fkey
+FOREIGN KEY (replaced_by) REFERENCES refresh_tokens(token_hash) ON DELETE SET NULL;
+
+-- +goose Down
+-- hashes can't be turned back into tokens, everyone has to sign in again
+DELETE FROM refresh_tokens;
+
+ALTER TABLE refresh_tokens
+RENAME COLUMN token_hash TO token;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- where each refresh token was issued, for listing a user's sessions
+ALTER TABLE refresh_tokens
+ADD COLUMN user_agent TEXT NOT NULL
+DEFAULT '';
+
+ALTER TABLE refresh_tokens
+ADD COLUMN ip_address TEXT NOT NULL
+DEFAULT '';
+
+CREATE INDEX refresh_tokens_user_id_idx ON refresh_tokens (user_id);
+
+-- +goose Down
+DROP INDEX refresh_tokens_user_id_idx;
+
+ALTER TABLE refresh_tokens
+DROP COLUMN ip_address;
+
+ALTER TABLE refresh_tokens
+DROP COLUMN user_agent;
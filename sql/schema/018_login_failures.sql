@@ -0,0 +1,10 @@
+-- +goose Up
+-- failed logins per account or client IP, shared by every instance
+CREATE TABLE login_failures (
+    key             TEXT PRIMARY KEY,
+    failures        INTEGER NOT NULL,
+    last_failure_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE login_failures;
@@ -0,0 +1,31 @@
+-- +goose Up
+-- accounts from before verification existed are trusted as they are
+ALTER TABLE users
+ADD COLUMN email_verified_at TIMESTAMP;
+
+UPDATE users SET email_verified_at = created_at;
+
+-- single use tokens sent by email, only their hashes are stored
+CREATE TABLE email_verifications (
+    token_hash  TEXT PRIMARY KEY,
+    created_at  TIMESTAMP NOT NULL,
+    user_id     UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    email       TEXT NOT NULL,
+    expires_at  TIMESTAMP NOT NULL,
+    used_at     TIMESTAMP
+);
+
+CREATE TABLE password_resets (
+    token_hash  TEXT PRIMARY KEY,
+    created_at  TIMESTAMP NOT NULL,
+    user_id     UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at  TIMESTAMP NOT NULL,
+    used_at     TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE password_resets;
+DROP TABLE email_verifications;
+
+ALTER TABLE users
+DROP COLUMN email_verified_at;
@@ -0,0 +1,26 @@
+-- +goose Up
+-- accounts at an OpenID Connect provider, subjects are only unique per issuer
+CREATE TABLE user_identities (
+    issuer      TEXT NOT NULL,
+    subject     TEXT NOT NULL,
+    user_id     UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    email       TEXT NOT NULL,
+    created_at  TIMESTAMP NOT NULL,
+    PRIMARY KEY (issuer, subject)
+);
+
+CREATE INDEX user_identities_user_id_idx ON user_identities (user_id);
+
+-- a login started at the provider, found again by the hash of its state when the user comes back
+CREATE TABLE oidc_logins (
+    state_hash      TEXT PRIMARY KEY,
+    code_verifier   TEXT NOT NULL,
+    nonce           TEXT NOT NULL,
+    created_at      TIMESTAMP NOT NULL,
+    expires_at      TIMESTAMP NOT NULL,
+    used_at         TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE oidc_logins;
+DROP TABLE user_identities;
@@ -0,0 +1,18 @@
+-- +goose Up
+-- long lived tokens for bots and integrations, only the hash is stored
+CREATE TABLE personal_tokens (
+    id              UUID PRIMARY KEY,
+    user_id         UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name            TEXT NOT NULL,
+    token_hash      TEXT UNIQUE NOT NULL,
+    scope           TEXT NOT NULL,
+    created_at      TIMESTAMP NOT NULL,
+    last_used_at    TIMESTAMP,
+    expires_at      TIMESTAMP,
+    revoked_at      TIMESTAMP
+);
+
+CREATE INDEX personal_tokens_user_id_idx ON personal_tokens (user_id);
+
+-- +goose Down
+DROP TABLE personal_tokens;
@@ -0,0 +1,30 @@
+-- +goose Up
+-- a user's Chirpy Red subscription at Polka, they're Chirpy Red while it's active and inside its period
+CREATE TABLE subscriptions (
+    user_id             UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    created_at          TIMESTAMP NOT NULL,
+    updated_at          TIMESTAMP NOT NULL,
+    plan                TEXT NOT NULL,
+    status              TEXT NOT NULL CHECK (status IN ('active', 'canceled', 'expired')),
+    current_period_end  TIMESTAMP
+);
+
+-- users upgraded before subscriptions were tracked stay Chirpy Red until Polka says otherwise
+INSERT INTO subscriptions (user_id, created_at, updated_at, plan, status)
+SELECT id, NOW(), NOW(), 'chirpy_red', 'active' FROM users
+WHERE is_chirpy_red;
+
+ALTER TABLE users DROP COLUMN is_chirpy_red;
+
+-- +goose Down
+ALTER TABLE users
+ADD COLUMN is_chirpy_red BOOLEAN NOT NULL
+DEFAULT false;
+
+UPDATE users SET is_chirpy_red = true
+WHERE id IN (
+    SELECT user_id FROM subscriptions
+    WHERE status = 'active' AND (current_period_end IS NULL OR current_period_end > NOW())
+);
+
+DROP TABLE subscriptions;
@@ -0,0 +1,25 @@
+-- +goose Up
+-- every webhook delivery we've received, so retries are only applied once and failures can be replayed
+CREATE TABLE webhook_events (
+    id              UUID PRIMARY KEY,
+    provider        TEXT NOT NULL,
+    event_id        TEXT NOT NULL,
+    event_type      TEXT NOT NULL,
+    payload         JSONB NOT NULL,
+    received_at     TIMESTAMP NOT NULL,
+    occurred_at     TIMESTAMP,
+    status          TEXT NOT NULL CHECK (status IN ('pending', 'processed', 'ignored', 'stale', 'failed')),
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    last_error      TEXT,
+    processed_at    TIMESTAMP,
+    UNIQUE (provider, event_id)
+);
+
+CREATE INDEX webhook_events_status_idx ON webhook_events (status, received_at DESC, id DESC);
+
+-- when the newest event applied to the subscription happened, older events arriving late are skipped
+ALTER TABLE subscriptions ADD COLUMN last_event_at TIMESTAMP;
+
+-- +goose Down
+ALTER TABLE subscriptions DROP COLUMN last_event_at;
+DROP TABLE webhook_events;
@@ -0,0 +1,32 @@
+-- +goose Up
+-- integrators subscribed to Chirpy events, events is a space separated list like token scopes
+CREATE TABLE webhook_subscriptions (
+    id                  UUID PRIMARY KEY,
+    created_at          TIMESTAMP NOT NULL,
+    updated_at          TIMESTAMP NOT NULL,
+    url                 TEXT NOT NULL,
+    events              TEXT NOT NULL,
+    encrypted_secret    TEXT NOT NULL
+);
+
+-- one row per event sent to a subscription, kept as its delivery log
+CREATE TABLE webhook_deliveries (
+    id                  UUID PRIMARY KEY,
+    subscription_id     UUID NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+    event_type          TEXT NOT NULL,
+    payload             JSONB NOT NULL,
+    status              TEXT NOT NULL CHECK (status IN ('pending', 'succeeded', 'dead')),
+    attempts            INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at     TIMESTAMP NOT NULL,
+    last_status_code    INTEGER,
+    last_error          TEXT,
+    created_at          TIMESTAMP NOT NULL,
+    delivered_at        TIMESTAMP
+);
+
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX webhook_deliveries_subscription_idx ON webhook_deliveries (subscription_id, created_at DESC, id DESC);
+
+-- +goose Down
+DROP TABLE webhook_deliveries;
+DROP TABLE webhook_subscriptions;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- uploads never attached to a chirp are swept up after a while
+CREATE INDEX media_unattached_idx ON media (created_at) WHERE chirp_id IS NULL;
+
+-- +goose Down
+DROP INDEX media_unattached_idx;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- emails are compared case insensitively, so they're stored lower cased. Fails if two accounts only differ by case,
+-- those have to be merged by hand first
+UPDATE users SET email = lower(email);
+UPDATE email_verifications SET email = lower(email);
+ALTER TABLE users ADD CONSTRAINT users_email_lower CHECK (email = lower(email));
+
+-- +goose Down
+ALTER TABLE users DROP CONSTRAINT users_email_lower;